@@ -0,0 +1,220 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+// Package to log every request a site serves, in the Apache Common or Combined format, or as JSON.
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Formats of the lines written to the log.
+const (
+	Combined = "combined" // Apache Combined Log Format, the default
+	Common   = "common"   // Apache Common Log Format
+	JSON     = "json"     // one JSON object per line, includes the host and duration
+)
+
+// Handler writes a line to the log after each request is served.
+type Handler struct {
+	next   http.Handler
+	out    io.Writer
+	format string
+	now    func() time.Time
+}
+
+// New wraps `next` so each request it serves is written to `out` in `format`.
+func New(next http.Handler, out io.Writer, format string) (*Handler, error) {
+	switch format {
+	case "":
+		format = Combined
+	case Combined, Common, JSON:
+	default:
+		return nil, fmt.Errorf("Unknown access log format %q, expecting %v, %v or %v", format, Combined, Common, JSON)
+	}
+	return &Handler{
+		next:   next,
+		out:    out,
+		format: format,
+		now:    time.Now,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	start := h.now()
+	rw := &responseWriter{ResponseWriter: w}
+	h.next.ServeHTTP(rw, req)
+	e := entry{
+		req:      req,
+		start:    start,
+		duration: h.now().Sub(start),
+		status:   rw.Status(),
+		size:     rw.size,
+	}
+	var line []byte
+	switch h.format {
+	case Common:
+		line = e.common()
+	case JSON:
+		line = e.json()
+	default:
+		line = e.combined()
+	}
+	h.out.Write(line)
+}
+
+// entry is everything logged about a single request.
+type entry struct {
+	req      *http.Request
+	start    time.Time
+	duration time.Duration
+	status   int
+	size     int64
+}
+
+// common is `host ident user [time] "request" status bytes`
+func (e *entry) common() []byte {
+	return []byte(e.commonPrefix() + "\n")
+}
+
+// combined is the common format followed by `"referer" "user-agent"`
+func (e *entry) combined() []byte {
+	return []byte(fmt.Sprintf("%s %s %s\n", e.commonPrefix(), quote(e.req.Referer()), quote(e.req.UserAgent())))
+}
+
+func (e *entry) commonPrefix() string {
+	size := "-"
+	if e.size > 0 {
+		size = strconv.FormatInt(e.size, 10)
+	}
+	request := fmt.Sprintf("%s %s %s", e.req.Method, e.req.URL.RequestURI(), e.req.Proto)
+	return fmt.Sprintf("%s - %s [%s] %s %d %s",
+		remoteHost(e.req.RemoteAddr),
+		dash(user(e.req)),
+		e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		quote(request),
+		e.status,
+		size,
+	)
+}
+
+type jsonEntry struct {
+	Time       string  `json:"time"`
+	Host       string  `json:"host"`
+	Remote     string  `json:"remote"`
+	User       string  `json:"user,omitempty"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	Size       int64   `json:"size"`
+	DurationMS float64 `json:"duration_ms"`
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+func (e *entry) json() []byte {
+	data, _ := json.Marshal(jsonEntry{
+		Time:       e.start.Format(time.RFC3339Nano),
+		Host:       e.req.Host,
+		Remote:     remoteHost(e.req.RemoteAddr),
+		User:       user(e.req),
+		Method:     e.req.Method,
+		URI:        e.req.URL.RequestURI(),
+		Proto:      e.req.Proto,
+		Status:     e.status,
+		Size:       e.size,
+		DurationMS: float64(e.duration) / float64(time.Millisecond),
+		Referer:    e.req.Referer(),
+		UserAgent:  e.req.UserAgent(),
+	})
+	return append(data, '\n')
+}
+
+// remoteHost is the ip of the client without the port.
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return dash(remoteAddr)
+}
+
+// user is the basic auth user name, if there is one.
+func user(req *http.Request) string {
+	if name, _, ok := req.BasicAuth(); ok {
+		return name
+	}
+	return ""
+}
+
+// dash is how the log formats write an empty field.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// quote wraps the field in quotes, escaping anything that could break the line apart.
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
+
+// responseWriter remembers the status and size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Status is the code sent to the client, 200 if the handler never set one.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Flush passes through to the real writer, for streaming responses.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the real writer, for upgraded connections like WebSockets.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("accesslog: %T can't be hijacked", w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
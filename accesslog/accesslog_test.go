@@ -0,0 +1,103 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testHandler(t *testing.T, format string) (*Handler, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+	h, err := New(next, out, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2018, 1, 28, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+	calls := 0
+	h.now = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls-1) * 1500 * time.Microsecond)
+	}
+	return h, out
+}
+
+func testRequest(path string) *http.Request {
+	req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Referer", "http://other.example.com/")
+	req.Header.Set("User-Agent", "test \"agent\"")
+	return req
+}
+
+func Test_Handler_formats(t *testing.T) {
+	type test struct {
+		format   string
+		path     string
+		expected string
+	}
+	tests := []test{
+		{"", "/", `192.0.2.1 - - [28/Jan/2018:13:55:36 -0700] "GET / HTTP/1.1" 200 5 "http://other.example.com/" "test \"agent\""` + "\n"},
+		{Combined, "/missing", `192.0.2.1 - - [28/Jan/2018:13:55:36 -0700] "GET /missing HTTP/1.1" 404 19 "http://other.example.com/" "test \"agent\""` + "\n"},
+		{Common, "/?a=b", `192.0.2.1 - - [28/Jan/2018:13:55:36 -0700] "GET /?a=b HTTP/1.1" 200 5` + "\n"},
+	}
+	for i := range tests {
+		// GIVEN a handler in the format
+		h, out := testHandler(t, tests[i].format)
+
+		// WHEN a request is served
+		h.ServeHTTP(httptest.NewRecorder(), testRequest(tests[i].path))
+
+		// THEN the line should be logged in the format
+		if out.String() != tests[i].expected {
+			t.Errorf("%q expecting\n%v got\n%v", tests[i].format, tests[i].expected, out.String())
+		}
+	}
+}
+
+func Test_Handler_json(t *testing.T) {
+	// GIVEN a JSON handler
+	h, out := testHandler(t, JSON)
+
+	// WHEN a request is served
+	h.ServeHTTP(httptest.NewRecorder(), testRequest("/missing"))
+
+	// THEN every field should be logged
+	var e jsonEntry
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatal(err, out.String())
+	}
+	expected := jsonEntry{
+		Time:       "2018-01-28T13:55:36-07:00",
+		Host:       "example.com",
+		Remote:     "192.0.2.1",
+		Method:     "GET",
+		URI:        "/missing",
+		Proto:      "HTTP/1.1",
+		Status:     404,
+		Size:       19,
+		DurationMS: 1.5,
+		Referer:    "http://other.example.com/",
+		UserAgent:  `test "agent"`,
+	}
+	if e != expected {
+		t.Errorf("Expecting %+v got %+v", expected, e)
+	}
+}
+
+func Test_New_badFormat(t *testing.T) {
+	if _, err := New(http.NotFoundHandler(), &bytes.Buffer{}, "apache"); err == nil {
+		t.Error("Expecting an error for an unknown format")
+	}
+}
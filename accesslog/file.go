@@ -0,0 +1,141 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is a log file that is rotated when it gets too big.
+// Sites that log to the same filename with the same rotation share the same File, it's closed when the last one is done.
+type File struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	refs       int
+}
+
+// fileKey is what a File is shared by, a site with other rotation settings gets its own,
+// like the new config of a site while a reload replaces it.
+type fileKey struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+}
+
+var openFiles = struct {
+	sync.Mutex
+	files map[fileKey]*File
+}{files: make(map[fileKey]*File)}
+
+// Open appends to the log at `filename`.
+// Once writing would grow it past `maxSize` bytes it is renamed to `filename`.1 and a new one is started,
+// keeping `maxBackups` old files.  A `maxSize` of 0 never rotates.
+func Open(filename string, maxSize int64, maxBackups int) (*File, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	if f, ok := openFiles.files[fileKey{filename, maxSize, maxBackups}]; ok {
+		f.refs++
+		return f, nil
+	}
+	f := &File{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		refs:       1,
+	}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+	openFiles.files[f.key()] = f
+	return f, nil
+}
+
+// Write adds to the log, rotating it first if it would get too big.
+func (f *File) Write(data []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, fmt.Errorf("Access log %v is closed", f.filename)
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err = f.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = f.file.Write(data)
+	f.size += int64(n)
+	return
+}
+
+// Close stops writing to the log once every site that opened it has closed it.
+func (f *File) Close() error {
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	f.refs--
+	if f.refs > 0 {
+		return nil
+	}
+	delete(openFiles.files, f.key())
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *File) key() fileKey {
+	return fileKey{f.filename, f.maxSize, f.maxBackups}
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.filename), 0755); err != nil {
+		return fmt.Errorf("Couldn't make folder for access log %v: %v", f.filename, err)
+	}
+	file, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Couldn't open access log %v: %v", f.filename, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Couldn't open access log %v: %v", f.filename, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts filename.1 to filename.2 and so on, dropping the oldest, then starts a new file.
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("Couldn't close access log %v: %v", f.filename, err)
+	}
+	f.file = nil
+	if f.maxBackups <= 0 {
+		os.Remove(f.filename)
+	} else {
+		os.Remove(backupName(f.filename, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(backupName(f.filename, i), backupName(f.filename, i+1))
+		}
+		if err := os.Rename(f.filename, backupName(f.filename, 1)); err != nil {
+			return fmt.Errorf("Couldn't rotate access log %v: %v", f.filename, err)
+		}
+	}
+	return f.open()
+}
+
+func backupName(filename string, n int) string {
+	return fmt.Sprintf("%s.%d", filename, n)
+}
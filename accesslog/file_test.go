@@ -0,0 +1,98 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package accesslog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_File_rotate(t *testing.T) {
+	// GIVEN a log that rotates after 10 bytes, keeping 2 old files
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "logs", "access.log")
+	f, err := Open(filename, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// WHEN more lines are written than fit
+	lines := []string{"line1\n", "line2\n", "line3\n", "line4\n"}
+	for i := range lines {
+		if _, err = f.Write([]byte(lines[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// THEN the newest line should be in the log, and the older ones rotated, dropping the oldest
+	expected := map[string]string{
+		filename:        "line4\n",
+		filename + ".1": "line3\n",
+		filename + ".2": "line2\n",
+	}
+	for name, contents := range expected {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(data) != contents {
+			t.Errorf("%v expecting %q got %q", name, contents, data)
+		}
+	}
+	if _, err = os.Stat(filename + ".3"); !os.IsNotExist(err) {
+		t.Error("Expecting only 2 backups")
+	}
+}
+
+func Test_Open_shared(t *testing.T) {
+	// GIVEN a log opened twice
+	dir, err := ioutil.TempDir("", "accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "access.log")
+	a, err := Open(filename, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(filename, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN it should be the same file
+	if a != b {
+		t.Error("Expecting the same File for the same filename")
+	}
+
+	// WHEN it's opened with other rotation settings THEN that's a File of its own
+	other, err := Open(filename, 1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == a || other.maxSize != 1024 || other.maxBackups != 1 {
+		t.Errorf("Expecting a File with its own settings got %+v", other)
+	}
+	other.Close()
+
+	// WHEN one is closed
+	a.Close()
+
+	// THEN the other should still write
+	if _, err = b.Write([]byte("still open\n")); err != nil {
+		t.Error(err)
+	}
+	b.Close()
+	if _, err = b.Write([]byte("closed\n")); err == nil {
+		t.Error("Expecting an error writing after the last close")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/robert-wallis/webd/multisite"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// listCerts prints the certificate of every host in a sites.yaml file, and renews them ahead of time with `-renew`.
+func listCerts(args []string, infoLog, errorLog *log.Logger) {
+	flags := flag.NewFlagSet("certs", flag.ExitOnError)
+	renew := flags.Bool("renew", false, "get or renew Let's Encrypt certificates now, instead of on the first TLS handshake")
+	renewBefore := flags.Duration("renew-before", 30*24*time.Hour, "with -renew, renew certificates that expire within this long")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s certs [flags] <sites.yaml>\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(ExitCertsParam)
+	}
+	configFile := flags.Arg(0)
+
+	if *renew {
+		if err := multisite.RenewCertificates(configFile, *renewBefore, infoLog, errorLog); err != nil {
+			errorLog.Println(err)
+			os.Exit(ExitCertsRenew)
+		}
+	}
+	infos, err := multisite.Certificates(configFile)
+	if err != nil {
+		errorLog.Println(err)
+		os.Exit(ExitCertsParam)
+	}
+	printCerts(os.Stdout, infos, time.Now())
+}
+
+// printCerts writes a table of the certificates, one host per line.
+func printCerts(w io.Writer, infos []*multisite.CertInfo, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tSOURCE\tALLOWED\tISSUER\tNAMES\tEXPIRES")
+	for i := range infos {
+		info := infos[i]
+		source := info.Source
+		if len(source) == 0 {
+			source = "-"
+		}
+		allowed := "no"
+		if info.Allowed {
+			allowed = "yes"
+		}
+		issuer, names, expires := "-", "-", "-"
+		switch {
+		case info.Err != nil:
+			expires = "error: " + info.Err.Error()
+		case info.Cached:
+			issuer = info.Issuer
+			names = strings.Join(info.Names, ",")
+			expires = fmt.Sprintf("%v (%v days)", info.NotAfter.Format("2006-01-02"), int(info.NotAfter.Sub(now).Hours()/24))
+		case len(info.Source) > 0:
+			expires = "not issued yet"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", info.Host, source, allowed, issuer, names, expires)
+	}
+	tw.Flush()
+}
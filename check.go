@@ -0,0 +1,42 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/robert-wallis/webd/multisite"
+	"log"
+	"os"
+)
+
+// checkConfig reports every problem in a sites.yaml file, the same checks that run before serving it.
+func checkConfig(args []string, infoLog, errorLog *log.Logger) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s check <sites.yaml>\n", os.Args[0])
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(ExitCheckParam)
+	}
+	if err := multisite.Check(flags.Arg(0)); err != nil {
+		logError(err, errorLog)
+		os.Exit(ExitCheck)
+	}
+	infoLog.Println(flags.Arg(0), "is ok")
+}
+
+// logError logs each of the multisite.Problems on its own line, or any other error.
+func logError(err error, errorLog *log.Logger) {
+	problems, ok := err.(multisite.Problems)
+	if !ok {
+		errorLog.Println(err)
+		return
+	}
+	for p := range problems {
+		errorLog.Println(problems[p])
+	}
+	errorLog.Println(len(problems), "problems found")
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+// Package to compress responses with brotli or gzip, and serve static files that were compressed ahead of time.
+package compress
+
+import (
+	"context"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encodings that can be used, by their Content-Encoding name.
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// DefaultTypes are compressed when the site doesn't list its own, images and fonts other than svg already are.
+var DefaultTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+// DefaultMinSize is the smallest response compressed when the site doesn't say, anything smaller barely shrinks.
+const DefaultMinSize = 1024
+
+// extensions of the precompressed files for each encoding.
+var extensions = map[string]string{
+	Brotli: ".br",
+	Gzip:   ".gz",
+}
+
+type handlerKey struct{}
+
+// Handler compresses what `next` writes, when the client accepts it and the type is worth it.
+type Handler struct {
+	next          http.Handler
+	enabled       bool
+	precompressed bool
+	encodings     []string // in the order they're preferred
+	types         []string
+	minSize       int
+}
+
+// New wraps `next` so its responses are compressed as `cfg` says.
+func New(next http.Handler, cfg config.ConfigCompress) (*Handler, error) {
+	h := &Handler{
+		next:          next,
+		enabled:       cfg.Enabled,
+		precompressed: cfg.Precompressed,
+		encodings:     cfg.Encodings,
+		types:         cfg.Types,
+		minSize:       cfg.MinSize,
+	}
+	if len(h.encodings) == 0 {
+		h.encodings = []string{Brotli, Gzip}
+	}
+	for e := range h.encodings {
+		if _, ok := extensions[h.encodings[e]]; !ok {
+			return nil, fmt.Errorf("Unknown compress encoding %q, expecting %v or %v", h.encodings[e], Brotli, Gzip)
+		}
+	}
+	if len(h.types) == 0 {
+		h.types = DefaultTypes
+	}
+	if h.minSize <= 0 {
+		h.minSize = DefaultMinSize
+	}
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	req = req.WithContext(context.WithValue(req.Context(), handlerKey{}, h))
+	if !h.enabled || len(req.Header.Get("Range")) > 0 || len(req.Header.Get("Upgrade")) > 0 {
+		// a range is of the uncompressed body, and an upgraded connection isn't http anymore
+		h.next.ServeHTTP(w, req)
+		return
+	}
+	cw := &compressWriter{ResponseWriter: w, h: h}
+	if accepted := h.accepted(req); len(accepted) > 0 {
+		cw.encoding = accepted[0]
+	}
+	defer cw.Close()
+	h.next.ServeHTTP(cw, req)
+}
+
+// accepted are the encodings of the site the client accepts, the best first.
+// Ties go to the order the site prefers them in.
+func (h *Handler) accepted(req *http.Request) (encodings []string) {
+	q := acceptEncoding(req.Header.Get("Accept-Encoding"))
+	for _, encoding := range h.encodings {
+		if weight(q, encoding) > 0 {
+			encodings = append(encodings, encoding)
+		}
+	}
+	sort.SliceStable(encodings, func(i, j int) bool {
+		return weight(q, encodings[i]) > weight(q, encodings[j])
+	})
+	return
+}
+
+// acceptEncoding parses an Accept-Encoding header into the q value of each encoding.
+func acceptEncoding(header string) map[string]float64 {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(name) == 0 {
+			continue
+		}
+		value := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[len("q="):], 64); err == nil {
+					value = v
+				}
+			}
+		}
+		q[name] = value
+	}
+	return q
+}
+
+// weight is the q value of `encoding`, or of * when it isn't listed.
+func weight(q map[string]float64, encoding string) float64 {
+	if v, ok := q[encoding]; ok {
+		return v
+	}
+	return q["*"]
+}
+
+// compressible is true if the site compresses `contentType`.
+func (h *Handler) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range h.types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// addVary tells caches the response depends on Accept-Encoding.
+func addVary(header http.Header) {
+	for _, vary := range header["Vary"] {
+		for _, name := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	header.Add("Vary", "Accept-Encoding")
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package compress
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/robert-wallis/webd/config"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testBody = strings.Repeat("<p>compress me</p>\n", 100)
+
+func testHandler(t *testing.T, cfg config.ConfigCompress) *Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/small":
+			w.Write([]byte("<p>small</p>"))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(testBody))
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(testBody))
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Write([]byte(testBody))
+		}
+	})
+	h, err := New(next, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func Test_Handler_compress(t *testing.T) {
+	// GIVEN a site that compresses
+	h := testHandler(t, config.ConfigCompress{Enabled: true})
+	type test struct {
+		path           string
+		acceptEncoding string
+		encoding       string
+		vary           bool
+	}
+	tests := []test{
+		{"/", "gzip, deflate, br", Brotli, true},
+		{"/", "gzip", Gzip, true},
+		{"/", "br;q=0.5, gzip", Gzip, true},
+		{"/", "*", Brotli, true},
+		{"/", "br;q=0, gzip;q=0", "", true},
+		{"/", "", "", true},
+		{"/small", "gzip", "", false},
+		{"/image", "gzip", "", false},
+		{"/not-modified", "gzip", "", false},
+	}
+	for _, tt := range tests {
+		// WHEN the client asks with its Accept-Encoding
+		req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		if len(tt.acceptEncoding) > 0 {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		// THEN it gets the best encoding it accepts, and the body is the same once decoded
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%v %q expecting encoding %q got %q", tt.path, tt.acceptEncoding, tt.encoding, got)
+			continue
+		}
+		if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.vary {
+			t.Errorf("%v %q expecting vary %v got %v", tt.path, tt.acceptEncoding, tt.vary, got)
+		}
+		var body io.Reader = w.Body
+		switch tt.encoding {
+		case Gzip:
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gz
+		case Brotli:
+			body = brotli.NewReader(w.Body)
+		}
+		decoded, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.path == "/" && string(decoded) != testBody {
+			t.Errorf("%v %q body changed", tt.path, tt.acceptEncoding)
+		}
+		if tt.encoding != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("Expecting the type of the uncompressed body got %v", w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func Test_Handler_compress_options(t *testing.T) {
+	// GIVEN a site that only gzips, only css, from 10 bytes
+	h := testHandler(t, config.ConfigCompress{Enabled: true, Encodings: []string{Gzip}, Types: []string{"text/css"}, MinSize: 10})
+
+	// WHEN html is asked for THEN it isn't compressed
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Expecting html to not be compressed got %v", got)
+	}
+
+	// GIVEN html is allowed WHEN it's asked for THEN it's gzipped with a weak etag
+	h = testHandler(t, config.ConfigCompress{Enabled: true, Encodings: []string{Gzip}, Types: []string{"text/*"}, MinSize: 10})
+	req = httptest.NewRequest("GET", "http://example.com/etag", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get("Content-Encoding"); got != Gzip {
+		t.Errorf("Expecting gzip got %q", got)
+	}
+	if got := w.Header().Get("ETag"); got != `W/"v1"` {
+		t.Errorf("Expecting a weak etag got %v", got)
+	}
+
+	// GIVEN compression is off THEN nothing is compressed
+	h = testHandler(t, config.ConfigCompress{})
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get("Content-Encoding"); got != "" || w.Body.String() != testBody {
+		t.Errorf("Expecting no compression got %q", got)
+	}
+
+	// GIVEN an unknown encoding THEN it's an error
+	if _, err := New(http.NotFoundHandler(), config.ConfigCompress{Encodings: []string{"deflate"}}); err == nil {
+		t.Error("Expecting an error for deflate")
+	}
+}
+
+func Test_acceptEncoding(t *testing.T) {
+	got := acceptEncoding("gzip;q=0.8, BR, identity; q=0.5, *;q=0")
+	expected := map[string]float64{"gzip": 0.8, "br": 1, "identity": 0.5, "*": 0}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expecting %v got %v", expected, got)
+	}
+}
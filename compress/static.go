@@ -0,0 +1,84 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package compress
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileServer is http.FileServer for `dir`, that serves the precompressed files when the site allows them.
+func FileServer(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if Precompressed(w, req, dir) {
+			return
+		}
+		files.ServeHTTP(w, req)
+	})
+}
+
+// Precompressed serves the .br or .gz file next to the file in `dir` the request is for, if the client accepts it.
+// It's false when the site doesn't use precompressed files, or there isn't one the client can take,
+// then the file should be served as usual.
+func Precompressed(w http.ResponseWriter, req *http.Request, dir string) bool {
+	h, ok := req.Context().Value(handlerKey{}).(*Handler)
+	if !ok || !h.precompressed || strings.HasSuffix(req.URL.Path, "/index.html") {
+		// http.FileServer redirects index.html to the folder
+		return false
+	}
+	name := path.Clean("/" + req.URL.Path)
+	if strings.HasSuffix(req.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	file := filepath.Join(dir, filepath.FromSlash(name))
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return false
+	}
+	accepted := make(map[string]bool)
+	for _, encoding := range h.accepted(req) {
+		accepted[encoding] = true
+		f, err := os.Open(file + extensions[encoding])
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		header := w.Header()
+		addVary(header)
+		header.Set("Content-Encoding", encoding)
+		header.Set("Content-Type", contentType(file))
+		http.ServeContent(w, req, name, info.ModTime(), f)
+		return true
+	}
+	for _, encoding := range h.encodings {
+		if _, err := os.Stat(file + extensions[encoding]); err == nil && !accepted[encoding] {
+			// this client gets the file as is, but others won't
+			addVary(w.Header())
+			break
+		}
+	}
+	return false
+}
+
+// contentType is the type of the uncompressed file, by its extension or its first bytes.
+func contentType(file string) string {
+	if t := mime.TypeByExtension(filepath.Ext(file)); len(t) > 0 {
+		return t
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
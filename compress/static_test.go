@@ -0,0 +1,86 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package compress
+
+import (
+	"github.com/robert-wallis/webd/config"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FileServer_precompressed(t *testing.T) {
+	// GIVEN a static folder with a css file, its brotli and gzip versions, and a js file without any
+	dir, err := ioutil.TempDir("", "precompressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"style.css":    "body {}",
+		"style.css.br": "brotli",
+		"style.css.gz": "gzip",
+		"site.js":      "var x;",
+	}
+	for name, data := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := New(FileServer(dir), config.ConfigCompress{Precompressed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type test struct {
+		path           string
+		acceptEncoding string
+		body           string
+		encoding       string
+		vary           bool
+	}
+	tests := []test{
+		{"/style.css", "gzip, br", "brotli", Brotli, true},
+		{"/style.css", "gzip", "gzip", Gzip, true},
+		{"/style.css", "", "body {}", "", true},
+		{"/site.js", "gzip, br", "var x;", "", false},
+		{"/style.css.br", "gzip, br", "brotli", "", false},
+	}
+	for _, tt := range tests {
+		// WHEN the file is asked for
+		req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		if len(tt.acceptEncoding) > 0 {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		// THEN the precompressed file is sent when the client takes it, as the type of the original
+		if w.Code != 200 || w.Body.String() != tt.body {
+			t.Errorf("%v %q expecting %q got %v %q", tt.path, tt.acceptEncoding, tt.body, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%v %q expecting encoding %q got %q", tt.path, tt.acceptEncoding, tt.encoding, got)
+		}
+		if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.vary {
+			t.Errorf("%v %q expecting vary %v got %v", tt.path, tt.acceptEncoding, tt.vary, got)
+		}
+		if tt.path == "/style.css" && w.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+			t.Errorf("%v expecting text/css got %v", tt.path, w.Header().Get("Content-Type"))
+		}
+	}
+
+	// GIVEN precompressed files are off WHEN the css is asked for THEN the original is sent
+	h, err = New(FileServer(dir), config.ConfigCompress{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "http://example.com/style.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Body.String() != "body {}" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expecting the original got %q", w.Body.String())
+	}
+}
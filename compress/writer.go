@@ -0,0 +1,136 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package compress
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressWriter holds back the start of the response until it knows if it's worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	h          *Handler
+	encoding   string // the client's choice, empty when it doesn't accept any
+	status     int
+	buf        []byte         // written before deciding
+	decided    bool           // the header has been sent
+	compressor io.WriteCloser // nil when the response isn't compressed
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if !bodyAllowed(status) {
+		w.decide()
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.h.minSize {
+			return len(p), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.compressor != nil {
+		return w.compressor.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide sends the header, compressing if the response is allowed and big enough, then writes what was held back.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	eligible := bodyAllowed(w.status) && w.status != http.StatusPartialContent && len(header.Get("Content-Encoding")) == 0
+	if eligible && len(w.buf) > 0 && len(header.Get("Content-Type")) == 0 {
+		// net/http would sniff the compressed bytes
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	eligible = eligible && w.h.compressible(header.Get("Content-Type")) && len(w.buf) >= w.h.minSize
+	if eligible {
+		addVary(header)
+	}
+	if eligible && len(w.encoding) > 0 {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		if etag := header.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+			// the bytes are different, but mean the same
+			header.Set("ETag", "W/"+etag)
+		}
+		w.compressor = newCompressor(w.ResponseWriter, w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what's been written so far, compressed if it's being compressed.
+func (w *compressWriter) Flush() {
+	if !w.decided && w.status != 0 {
+		w.decide()
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close sends a response too small to compress, and finishes the compressed stream.
+func (w *compressWriter) Close() error {
+	if !w.decided && w.status != 0 {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+	return nil
+}
+
+// Unwrap is for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newCompressor(w io.Writer, encoding string) io.WriteCloser {
+	if encoding == Brotli {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	}
+	return gzip.NewWriter(w)
+}
+
+// bodyAllowed is false for the statuses that never have a body.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+// ConfigACME is how certificates are ordered for `letsencrypt` sites.
+// The `acme` section at the top of the settings applies to every site, a site's own `acme` section overrides it.
+//
+//	acme:
+//	  directory: https://acme-staging-v02.api.letsencrypt.org/directory
+//	  cache: /var/lib/webd/autocert
+//	  email: admin@example.com
+//	  keytype: rsa
+//	  eab:
+//	    keyid: kid-1
+//	    hmackey: base64url-encoded-key
+type ConfigACME struct {
+	Directory string    // ACME directory url, empty is the Let's Encrypt production directory
+	Cache     string    // folder for the account key and certificates, the default is autocert in the working directory
+	Email     string    // contact for the account, a site's own email comes before the one in the settings
+	KeyType   string    // "ecdsa" (the default, with RSA for old clients) or "rsa"
+	EAB       ConfigEAB // external account binding, for CAs that require one
+}
+
+// ConfigEAB binds the ACME account to an account the CA already knows about.
+type ConfigEAB struct {
+	KeyID   string
+	HMACKey string // base64url encoded, as the CA gives it out
+}
+
+// KeyTypes allowed in ConfigACME.
+const (
+	KeyTypeECDSA = "ecdsa"
+	KeyTypeRSA   = "rsa"
+)
+
+// override returns `a` with the fields that are set in `site` replaced.
+func (a ConfigACME) override(site ConfigACME) ConfigACME {
+	for _, field := range []struct{ dst, src *string }{
+		{&a.Directory, &site.Directory},
+		{&a.Cache, &site.Cache},
+		{&a.Email, &site.Email},
+		{&a.KeyType, &site.KeyType},
+	} {
+		if len(*field.src) > 0 {
+			*field.dst = *field.src
+		}
+	}
+	if len(site.EAB.KeyID) > 0 {
+		a.EAB = site.EAB
+	}
+	return a
+}
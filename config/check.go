@@ -0,0 +1,134 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// unknownField is how yaml.v2 reports a key that doesn't match any field.
+var unknownField = regexp.MustCompile(`^line (\d+): field (.+) not found in type (.+)$`)
+
+// CheckKeys reports every key in the config file, and the files it includes, that isn't a setting, like a typo, with its line number.
+// Load ignores them, so a misspelled setting silently keeps its default.
+func CheckKeys(configFile string) (problems []error) {
+	problems = checkFileKeys(configFile)
+	settings, err := readSettings(configFile)
+	if err != nil {
+		return
+	}
+	files, err := settings.Include.Files()
+	if err != nil {
+		return append(problems, err)
+	}
+	for f := range files {
+		problems = append(problems, checkFileKeys(files[f])...)
+	}
+	return
+}
+
+// checkFileKeys reports the unknown keys of one config file.
+func checkFileKeys(configFile string) (problems []error) {
+	data, err := readFile(configFile)
+	if err != nil {
+		return []error{err}
+	}
+	var top interface{}
+	if err = yaml.Unmarshal(data, &top); err != nil {
+		return []error{fmt.Errorf("Error parsing yaml in %v: %v", configFile, err)}
+	}
+	switch top.(type) {
+	case []interface{}:
+		err = yaml.UnmarshalStrict(data, &[]*Config{})
+	case map[interface{}]interface{}:
+		err = yaml.UnmarshalStrict(data, &Settings{})
+	default:
+		return nil // LoadSettings reports it
+	}
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%v: %v", configFile, err))
+		}
+		return
+	}
+	for _, msg := range typeErr.Errors {
+		if m := unknownField.FindStringSubmatch(msg); m != nil {
+			if Format(configFile) != FormatYAML {
+				// the lines are those of the file converted to YAML
+				problems = append(problems, fmt.Errorf("%v: unknown key %q in %v", configFile, m[2], section(m[3])))
+				continue
+			}
+			problems = append(problems, fmt.Errorf("%v:%v: unknown key %q in %v", configFile, m[1], m[2], section(m[3])))
+			continue
+		}
+		problems = append(problems, fmt.Errorf("%v: %v", configFile, msg))
+	}
+	return
+}
+
+// section is the yaml name of a config type, like bind for config.ConfigBind.
+func section(typeName string) string {
+	switch name := strings.TrimPrefix(typeName, "config."); name {
+	case "Config":
+		return "site"
+	case "Settings":
+		return "settings"
+	default:
+		return strings.ToLower(strings.TrimPrefix(name, "Config"))
+	}
+}
+
+// CheckSites reports the problems in the sites that would stop them from being served as expected:
+// a site without a bind, a bind that isn't host:port, mounts that can't be told apart,
+// and a host or alias claimed twice on the same bind.
+func CheckSites(sites []*Config) (problems []error) {
+	for s := range sites {
+		site := sites[s]
+		if len(site.Host) == 0 {
+			problems = append(problems, fmt.Errorf("Site %v has no host", s+1))
+		}
+		if len(site.Bind.HTTP) == 0 && len(site.Bind.HTTPS) == 0 {
+			problems = append(problems, fmt.Errorf("%v has no http or https bind, it isn't served", site.Host))
+		}
+		for _, bind := range []string{site.Bind.HTTP, site.Bind.HTTPS} {
+			if len(bind) == 0 {
+				continue
+			}
+			if _, _, err := net.SplitHostPort(bind); err != nil {
+				problems = append(problems, fmt.Errorf("%v bind %q isn't host:port: %v", site.Host, bind, err))
+			}
+		}
+		problems = append(problems, checkMounts(site)...)
+	}
+	binds := GroupServers(sites)
+	for _, bind := range SortedBinds(binds) {
+		list := binds[bind]
+		claimed := make(map[string]*Config)
+		for c := range list {
+			for _, host := range list[c].HostList() {
+				host = strings.ToLower(host)
+				if first, ok := claimed[host]; ok && first != list[c] {
+					problems = append(problems, fmt.Errorf("%v is served on %v by both %v and %v", host, bind, first.Host, list[c].Host))
+					continue
+				}
+				claimed[host] = list[c]
+			}
+		}
+	}
+	return
+}
+
+// SortedBinds are the binds of GroupServers in order, so problems are always reported the same way.
+func SortedBinds(binds map[string][]*Config) (sorted []string) {
+	for bind := range binds {
+		sorted = append(sorted, bind)
+	}
+	sort.Strings(sorted)
+	return
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_CheckKeys(t *testing.T) {
+	// GIVEN a config with typos in a site and in the settings
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "sites.yaml")
+	data := "admin:\n  bnd: :9100\nsites:\n  - host: example.com\n    pth: example\n    bind:\n      htps: :443\n"
+	if err = ioutil.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN the keys are checked
+	problems := CheckKeys(configFile)
+
+	// THEN every unknown key is reported with its line
+	expected := []string{
+		configFile + `:2: unknown key "bnd" in admin`,
+		configFile + `:5: unknown key "pth" in site`,
+		configFile + `:7: unknown key "htps" in bind`,
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expecting %v problems got %v", len(expected), problems)
+	}
+	for p := range problems {
+		if problems[p].Error() != expected[p] {
+			t.Errorf("Expecting %q got %q", expected[p], problems[p])
+		}
+	}
+
+	// THEN the test configs don't have any
+	for _, file := range []string{"../test_data/sites.yaml", "../test_data/settings.yaml", "../test_data/combine_sites.yaml", "../test_data/include.yaml"} {
+		if problems := CheckKeys(file); len(problems) > 0 {
+			t.Errorf("%v %v", file, problems)
+		}
+	}
+}
+
+func Test_CheckSites(t *testing.T) {
+	// GIVEN sites that claim the same host, one without a bind and one with a bad bind
+	sites := []*Config{
+		{Host: "example.com", Aliases: []string{"www.example.com"}, Bind: ConfigBind{HTTP: ":80", HTTPS: ":443"}},
+		{Host: "other.example.com", Aliases: []string{"WWW.example.com"}, Bind: ConfigBind{HTTP: ":80"}},
+		{Host: "secure.example.com", Aliases: []string{"www.example.com"}, Bind: ConfigBind{HTTPS: ":8443"}},
+		{Host: "nobind.example.com"},
+		{Host: "badbind.example.com", Bind: ConfigBind{HTTP: "80"}},
+	}
+
+	// WHEN they're checked
+	problems := CheckSites(sites)
+
+	// THEN each problem is reported, hosts can repeat on different binds
+	expected := []string{
+		"nobind.example.com has no http or https bind",
+		`badbind.example.com bind "80" isn't host:port`,
+		"www.example.com is served on :80 by both example.com and other.example.com",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expecting %v problems got %v", len(expected), problems)
+	}
+	for p := range problems {
+		if !strings.HasPrefix(problems[p].Error(), expected[p]) {
+			t.Errorf("Expecting %q got %q", expected[p], problems[p])
+		}
+	}
+}
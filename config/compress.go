@@ -0,0 +1,19 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+// ConfigCompress is how a site compresses its responses for clients that accept it.
+//
+//	compress:
+//	  enabled: true
+//	  precompressed: true
+//	  encodings: [br, gzip]
+//	  types: [text/html, text/css, application/javascript]
+//	  minsize: 1024
+type ConfigCompress struct {
+	Enabled       bool     // compress pages and static files on the fly
+	Precompressed bool     // serve style.css.br or style.css.gz when they're next to style.css
+	Encodings     []string // br and gzip in the order they're preferred, both by default
+	Types         []string // MIME types to compress, like text/html or text/*, text, javascript, json, xml and svg by default
+	MinSize       int      // bytes, smaller responses aren't worth compressing, 1024 by default
+}
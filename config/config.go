@@ -13,48 +13,194 @@ import (
 
 // Config represents the data of a single site in a sites.yaml file that describes how to configure websites.
 type Config struct {
-	Host        string   // the main hostname of this site
-	Aliases     []string // listed hosts will redirect here
-	Email       string   // admin to contact, used for acme
-	Static      bool     // true if path points directly to static content, false if it's a dynamic site
-	Path        string
-	Bind        ConfigBind
-	LetsEncrypt bool // use "Let's Encrypt" free auto CA to renew the SSL certificates
+	Host            string        // the main hostname of this site
+	Aliases         []string      // listed hosts will redirect here
+	Email           string        // admin to contact, used for acme
+	Static          bool          // true if path points directly to static content, false if it's a dynamic site
+	Proxy           ConfigProxy   // the site is an app served by other servers, instead of path
+	Mounts          []ConfigMount // more content under path prefixes of the host
+	Path            string
+	Bind            ConfigBind
+	LetsEncrypt     bool     // get and renew certificates automatically from the acme CA, "Let's Encrypt" unless configured
+	Cert            string   // PEM certificate file for https, like one from an internal CA
+	Key             string   // PEM private key file that goes with Cert
+	CertDir         string   // folder of PEM pairs, name.crt or name.pem with name.key, picked by the names in each certificate
+	NoHTTPSRedirect []string // paths still served on the http bind instead of redirecting to https, ending in / covers the folder
+	LiveRefresh     bool     // reload templates and content when their files change, for development
+	CacheControl    string   // Cache-Control of static files, fingerprinted ones from the `asset` template function are immutable
+	AccessLog       ConfigAccessLog
+	Headers         ConfigHeaders
+	Compress        ConfigCompress
+	ACME            ConfigACME // once loaded, the settings' acme section with this site's overrides
+}
+
+// ConfigAccessLog is where and how to log every request a site serves.
+type ConfigAccessLog struct {
+	Path       string // log file, empty doesn't log
+	Format     string // combined (the default), common or json
+	MaxSize    int    // megabytes before the log is rotated, 0 never rotates
+	MaxBackups int    // how many rotated logs to keep
+}
+
+// Settings is everything in a sites.yaml file.
+// The file is either just the list of sites, or a mapping with the sites and server wide settings.
+// ${ENV} variables are replaced anywhere in the file, ${ENV:-default} when ENV might not be set.
+//
+//	include: sites.d/*.yaml
+//	admin:
+//	  bind: 127.0.0.1:9100
+//	sites:
+//	  - host: example.com
+type Settings struct {
+	Include   Includes // more files with sites, relative to this one
+	Admin     ConfigAdmin
+	ACME      ConfigACME      // defaults for the acme section of every site
+	AccessLog ConfigAccessLog // requests for hosts that no site serves
+	Sites     []*Config
+}
+
+// ConfigAdmin is the server that shows how webd itself is doing, like /metrics.
+type ConfigAdmin struct {
+	Bind string // host and port of the admin server, empty doesn't start it
 }
 
 // ConfigBind is the host and port to bind a TCP socket to.
+// The HTTPS bind always serves TLS, whatever the port.
 type ConfigBind struct {
 	HTTP  string
 	HTTPS string
+	TLS   ConfigTLS // options for the HTTPS bind, every site on the bind has to agree
+}
+
+// ConfigTLS tunes the TLS of an HTTPS bind, empty fields keep the Go defaults.
+type ConfigTLS struct {
+	MinVersion            string   // "1.0", "1.1", "1.2" or "1.3"
+	CipherSuites          []string // names like TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS 1.3 suites can't be changed
+	ALPN                  []string // protocols offered in order, like [h2, http/1.1]
+	DisableSessionTickets bool     // don't resume sessions with tickets, for perfect forward secrecy
 }
 
 // Load opens the config file at the location in `configFile` and returns all the Config found within that file.
 func Load(configFile string) (sites []*Config, err error) {
-	var stream *os.File
+	settings, err := LoadSettings(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Sites, nil
+}
 
-	// load file
-	stream, err = os.Open(configFile)
+// LoadSettings opens the config file at the location in `configFile` and returns the sites and settings within that file.
+// The sites of the files it includes come after its own.
+func LoadSettings(configFile string) (settings *Settings, err error) {
+	if settings, err = readSettings(configFile); err != nil {
+		return nil, err
+	}
+	files, err := settings.Include.Files()
 	if err != nil {
-		return nil, fmt.Errorf("Couldn't Load Config: %v", err)
+		return nil, err
 	}
-	defer stream.Close()
-	data := &bytes.Buffer{}
-	if _, err = data.ReadFrom(stream); err != nil {
-		return nil, fmt.Errorf("Error reading %v: %v", configFile, err)
+	for f := range files {
+		fragment, err := readSettings(files[f])
+		if err != nil {
+			return nil, err
+		}
+		if len(fragment.Include) > 0 || fragment.Admin != (ConfigAdmin{}) || fragment.ACME != (ConfigACME{}) || fragment.AccessLog != (ConfigAccessLog{}) {
+			return nil, fmt.Errorf("%v is included by %v, it can only have sites", files[f], configFile)
+		}
+		settings.Sites = append(settings.Sites, fragment.Sites...)
+	}
+
+	// each site gets the acme settings with its own overrides, its email is the contact unless acme says otherwise
+	sites := settings.Sites
+	for c := range sites {
+		if len(sites[c].ACME.Email) == 0 {
+			sites[c].ACME.Email = sites[c].Email
+		}
+		sites[c].ACME = settings.ACME.override(sites[c].ACME)
 	}
-	if err = yaml.Unmarshal(data.Bytes(), &sites); err != nil {
+
+	return
+}
+
+// readSettings parses one config file, with the ${ENV} variables in its values expanded, and the paths made relative to its folder.
+func readSettings(configFile string) (settings *Settings, err error) {
+	data, err := readFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	settings = &Settings{}
+	var top interface{}
+	if err = yaml.Unmarshal(data, &top); err != nil {
 		return nil, fmt.Errorf("Error parsing yaml in %v: %v", configFile, err)
 	}
+	switch top.(type) {
+	case nil:
+	case []interface{}:
+		err = yaml.Unmarshal(data, &settings.Sites)
+	case map[interface{}]interface{}:
+		err = yaml.Unmarshal(data, settings)
+	default:
+		err = fmt.Errorf("expecting a list of sites, or a mapping with sites")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing yaml in %v: %v", configFile, err)
+	}
+	if err = expandEnv(settings); err != nil {
+		return nil, fmt.Errorf("Error in %v: %v", configFile, err)
+	}
 
 	// fix paths
 	dir := filepath.Dir(configFile)
+	if len(settings.ACME.Cache) > 0 {
+		settings.ACME.Cache = relativeTo(dir, settings.ACME.Cache)
+	}
+	if len(settings.AccessLog.Path) > 0 {
+		settings.AccessLog.Path = relativeTo(dir, settings.AccessLog.Path)
+	}
+	for i := range settings.Include {
+		settings.Include[i] = relativeTo(dir, settings.Include[i])
+	}
+	sites := settings.Sites
 	for c := range sites {
-		sites[c].Path = filepath.Join(dir, sites[c].Path)
+		sites[c].Path = relativeTo(dir, sites[c].Path)
+		for m := range sites[c].Mounts {
+			sites[c].Mounts[m].Path = relativeTo(dir, sites[c].Mounts[m].Path)
+		}
+		for _, path := range []*string{&sites[c].AccessLog.Path, &sites[c].Cert, &sites[c].Key, &sites[c].CertDir, &sites[c].ACME.Cache} {
+			if len(*path) > 0 {
+				*path = relativeTo(dir, *path)
+			}
+		}
 	}
-
 	return
 }
 
+// readFile reads a config file as YAML whatever its Format.
+func readFile(configFile string) ([]byte, error) {
+	stream, err := os.Open(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't Load Config: %v", err)
+	}
+	defer stream.Close()
+	data := &bytes.Buffer{}
+	if _, err = data.ReadFrom(stream); err != nil {
+		return nil, fmt.Errorf("Error reading %v: %v", configFile, err)
+	}
+	converted, err := toYAML(data.Bytes(), Format(configFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error in %v: %v", configFile, err)
+	}
+	return converted, nil
+}
+
+// relativeTo makes `path` relative to the `dir` of the config file, unless it's already absolute.
+func relativeTo(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
 // GroupServers combines configs by bind strings to determine which servers need to start on which ports.
 // This method is needed to host multiple hostnames on a single port.
 func GroupServers(sites []*Config) (configs map[string][]*Config) {
@@ -152,3 +152,112 @@ func Test_configSite_HostList(t *testing.T) {
 		}
 	}
 }
+
+func Test_relativeTo(t *testing.T) {
+	abs, _ := filepath.Abs("/var/log/access.log")
+	type test struct {
+		path     string
+		expected string
+	}
+	tests := []test{
+		{"site", filepath.Join("conf", "site")},
+		{"../logs/access.log", filepath.Join("logs", "access.log")},
+		{abs, abs},
+	}
+	for i := range tests {
+		got := relativeTo("conf", tests[i].path)
+		if got != tests[i].expected {
+			t.Errorf("%v expecting %v got %v", tests[i].path, tests[i].expected, got)
+		}
+	}
+}
+
+func Test_LoadSettings(t *testing.T) {
+	// GIVEN a config with server wide settings as well as sites
+	configFilename := "../test_data/settings.yaml"
+
+	// WHEN the settings are loaded
+	settings, err := LoadSettings(configFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the admin bind and the sites are both there
+	if settings.Admin.Bind != "127.0.0.1:9100" {
+		t.Errorf("Expecting 127.0.0.1:9100 got %v", settings.Admin.Bind)
+	}
+	if len(settings.Sites) != 2 {
+		t.Fatalf("Expecting 2 sites, got %v", len(settings.Sites))
+	}
+	if settings.Sites[0].Path != filepath.Clean("../example") {
+		t.Errorf("Expecting modified path based on file ../example got %v", settings.Sites[0].Path)
+	}
+
+	// WHEN a plain list of sites is loaded THEN there are no admin settings
+	settings, err = LoadSettings("../test_data/sites.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Admin.Bind != "" || len(settings.Sites) != 2 {
+		t.Errorf("Expecting no admin bind and 2 sites, got %q and %v", settings.Admin.Bind, len(settings.Sites))
+	}
+}
+
+func Test_LoadSettings_acme(t *testing.T) {
+	// GIVEN a config with an acme section, and a site that overrides some of it
+	settings, err := LoadSettings("../test_data/settings.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN a site without its own acme section gets the settings
+	expected := ConfigACME{
+		Directory: "https://acme-staging-v02.api.letsencrypt.org/directory",
+		Cache:     filepath.Clean("../test_data/autocert"),
+		Email:     "admin@example.com",
+	}
+	if settings.Sites[0].ACME != expected {
+		t.Errorf("Expecting %+v got %+v", expected, settings.Sites[0].ACME)
+	}
+
+	// THEN the overrides replace just the fields that are set, and the site's email is the contact
+	expected = ConfigACME{
+		Directory: "https://ca.internal/acme/directory",
+		Cache:     filepath.Clean("../test_data/autocert"),
+		Email:     "internal@example.com",
+		KeyType:   KeyTypeRSA,
+		EAB:       ConfigEAB{KeyID: "kid-1", HMACKey: "c2VjcmV0"},
+	}
+	if settings.Sites[1].ACME != expected {
+		t.Errorf("Expecting %+v got %+v", expected, settings.Sites[1].ACME)
+	}
+
+	// THEN a plain list of sites uses each site's email
+	sites, err := Load("../test_data/sites.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites[0].ACME.Email != "test@example.com" {
+		t.Errorf("Expecting test@example.com got %v", sites[0].ACME.Email)
+	}
+}
+
+func Test_LoadSettings_headers(t *testing.T) {
+	// GIVEN a site with a headers section
+	settings, err := LoadSettings("../test_data/settings.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the security headers and custom headers are loaded
+	headers := settings.Sites[0].Headers
+	if headers.HSTS != (ConfigHSTS{MaxAge: 31536000, IncludeSubDomains: true}) {
+		t.Errorf("hsts %+v", headers.HSTS)
+	}
+	if headers.CSP != "default-src 'self'" || headers.FrameOptions != "DENY" {
+		t.Errorf("headers %+v", headers)
+	}
+	if len(headers.Custom) != 1 || headers.Custom[0].Path != "/static/" || headers.Custom[0].Set["Cache-Control"] != "max-age=3600" {
+		t.Errorf("custom %+v", headers.Custom)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+)
+
+// Formats a config file can be in, picked by its extension, anything else is YAML.
+// The keys are the same in all of them.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// Format is the format of `configFile` by its extension.
+func Format(configFile string) string {
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// Convert rewrites a config file from one format to another, without loading it,
+// so relative paths, includes and ${ENV} variables stay as they are.
+// TOML can't be just a list, so a list of sites becomes a mapping with `sites`.
+func Convert(data []byte, from, to string) ([]byte, error) {
+	tree, err := decodeTree(data, from)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTree(tree, to)
+}
+
+// toYAML turns a config file in any format into YAML, which is how it's loaded.
+func toYAML(data []byte, format string) ([]byte, error) {
+	if format == FormatYAML {
+		return data, nil
+	}
+	return Convert(data, format, FormatYAML)
+}
+
+// decodeTree parses `data` into plain maps, lists and values.
+func decodeTree(data []byte, format string) (tree interface{}, err error) {
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &tree)
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	case FormatTOML:
+		var table map[string]interface{}
+		_, err = toml.Decode(string(data), &table)
+		tree = table
+	default:
+		return nil, fmt.Errorf("Unknown config format %q, expecting %v, %v or %v", format, FormatYAML, FormatJSON, FormatTOML)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %v: %v", format, err)
+	}
+	return plainTree(tree)
+}
+
+// encodeTree writes the plain tree in `format`.
+func encodeTree(tree interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(tree)
+	case FormatJSON:
+		data, err := json.MarshalIndent(tree, "", "  ")
+		return append(data, '\n'), err
+	case FormatTOML:
+		if list, ok := tree.([]interface{}); ok {
+			tree = map[string]interface{}{"sites": list}
+		}
+		buf := &bytes.Buffer{}
+		err := toml.NewEncoder(buf).Encode(tree)
+		return buf.Bytes(), err
+	default:
+		return nil, fmt.Errorf("Unknown config format %q, expecting %v, %v or %v", format, FormatYAML, FormatJSON, FormatTOML)
+	}
+}
+
+// plainTree makes every mapping a map[string]interface{}, every number an int64 or float64, and drops empty values,
+// which are what JSON and TOML can both write.
+func plainTree(tree interface{}) (interface{}, error) {
+	switch v := tree.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = value
+		}
+		return plainTree(m)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if value == nil {
+				continue
+			}
+			plain, err := plainTree(value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = plain
+		}
+		return m, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			plain, err := plainTree(v[i])
+			if err != nil {
+				return nil, err
+			}
+			list[i] = plain
+		}
+		return list, nil
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = v[i]
+		}
+		return plainTree(list)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case int:
+		return int64(v), nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func Test_LoadSettings_formats(t *testing.T) {
+	// GIVEN the same settings as yaml, json and toml
+	expected, err := LoadSettings("../test_data/settings.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"../test_data/settings.json", "../test_data/settings.toml"} {
+		// WHEN they're loaded
+		settings, err := LoadSettings(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// THEN they're the same, with the same path fixups
+		if !reflect.DeepEqual(settings, expected) {
+			t.Errorf("%v expecting %+v got %+v", file, expected, settings)
+		}
+		if problems := CheckKeys(file); len(problems) > 0 {
+			t.Errorf("%v %v", file, problems)
+		}
+	}
+}
+
+func Test_Convert(t *testing.T) {
+	// GIVEN a yaml config
+	data, err := ioutil.ReadFile("../test_data/settings.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := decodeTree(data, FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN it's converted through every format and back
+	from := FormatYAML
+	for _, to := range []string{FormatJSON, FormatTOML, FormatYAML} {
+		if data, err = Convert(data, from, to); err != nil {
+			t.Fatalf("%v to %v %v", from, to, err)
+		}
+		from = to
+	}
+
+	// THEN nothing changed
+	converted, err := decodeTree(data, FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(converted, original) {
+		t.Errorf("Expecting %v got %v", original, converted)
+	}
+
+	// GIVEN a plain list of sites, WHEN it's converted to toml THEN the sites are in a mapping
+	data, err = Convert([]byte("- host: example.com\n"), FormatYAML, FormatTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[[sites]]\n  host = \"example.com\"\n" {
+		t.Errorf("Expecting [[sites]] got %q", data)
+	}
+
+	// GIVEN an unknown format THEN it's an error
+	if _, err = Convert(data, FormatTOML, "ini"); err == nil {
+		t.Error("Expecting an error for ini")
+	}
+}
+
+func Test_Format(t *testing.T) {
+	for file, expected := range map[string]string{
+		"sites.yaml": FormatYAML,
+		"sites.yml":  FormatYAML,
+		"sites":      FormatYAML,
+		"sites.JSON": FormatJSON,
+		"sites.toml": FormatTOML,
+	} {
+		if got := Format(file); got != expected {
+			t.Errorf("%v expecting %v got %v", file, expected, got)
+		}
+	}
+}
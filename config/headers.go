@@ -0,0 +1,43 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+// ConfigHeaders are the security headers a site sends with every response, pages, static files, redirects and errors alike.
+// Empty fields don't send the header.
+//
+//	headers:
+//	  hsts:
+//	    maxage: 31536000
+//	    includesubdomains: true
+//	    preload: true
+//	  csp: "default-src 'self'"
+//	  frameoptions: DENY
+//	  referrerpolicy: strict-origin-when-cross-origin
+//	  permissionspolicy: "camera=(), microphone=()"
+//	  contenttypeoptions: nosniff
+//	  custom:
+//	    - path: /static/fonts/*
+//	      set:
+//	        Access-Control-Allow-Origin: "*"
+type ConfigHeaders struct {
+	HSTS               ConfigHSTS           // Strict-Transport-Security, only sent over https
+	CSP                string               // Content-Security-Policy
+	FrameOptions       string               // X-Frame-Options, like DENY or SAMEORIGIN
+	ReferrerPolicy     string               // Referrer-Policy
+	PermissionsPolicy  string               // Permissions-Policy
+	ContentTypeOptions string               // X-Content-Type-Options, which can only be nosniff
+	Custom             []ConfigCustomHeader // more headers for the paths that match, in order, later ones win
+}
+
+// ConfigHSTS tells browsers to only use https for the site, a MaxAge of 0 doesn't send it.
+type ConfigHSTS struct {
+	MaxAge            int // seconds the browser remembers to use https
+	IncludeSubDomains bool
+	Preload           bool // ask to be in the browsers' built in list, see hstspreload.org
+}
+
+// ConfigCustomHeader sets any headers on the responses for paths matching a glob.
+type ConfigCustomHeader struct {
+	Path string            // a path.Match glob like /static/*.css, ending in / covers the whole folder
+	Set  map[string]string // header names and values
+}
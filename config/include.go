@@ -0,0 +1,142 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Includes are globs of files with more sites, like sites.d/*.yaml, or folders where every config file is included.
+// It's either one glob or a list of them.
+//
+//	include: sites.d/
+//	sites:
+//	  - host: example.com
+type Includes []string
+
+// UnmarshalYAML allows a single glob as well as a list.
+func (i *Includes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var one string
+	if err := unmarshal(&one); err == nil {
+		*i = Includes{one}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*i = list
+	return nil
+}
+
+// Files are the files that match the includes, in order, each one only once.
+func (i Includes) Files() (files []string, err error) {
+	seen := make(map[string]bool)
+	for _, pattern := range i {
+		patterns := []string{pattern}
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			patterns = nil
+			for _, ext := range []string{"*.yaml", "*.yml", "*.json", "*.toml"} {
+				patterns = append(patterns, filepath.Join(pattern, ext))
+			}
+		}
+		for p := range patterns {
+			matches, err := filepath.Glob(patterns[p])
+			if err != nil {
+				return nil, fmt.Errorf("Bad include %q: %v", pattern, err)
+			}
+			for m := range matches {
+				if !seen[matches[m]] {
+					seen[matches[m]] = true
+					files = append(files, matches[m])
+				}
+			}
+		}
+	}
+	return
+}
+
+// Folders are where the included files are, or will be, so they can be watched for changes.
+func (i Includes) Folders() (folders []string) {
+	for _, pattern := range i {
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			folders = append(folders, pattern)
+			continue
+		}
+		dir := filepath.Dir(pattern)
+		for strings.ContainsAny(dir, "*?[") {
+			dir = filepath.Dir(dir)
+		}
+		folders = append(folders, dir)
+	}
+	return
+}
+
+// envVariable is ${NAME}, or ${NAME:-default} for when NAME isn't set, $${ is a literal ${.
+var envVariable = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces the ${ENV} variables in every string of `v`, what a config file was parsed into.
+// They're replaced after parsing, so a value can't add keys or sites of its own.
+// It's an error to use one that isn't set and has no default.
+func expandEnv(v interface{}) error {
+	var missing []string
+	expandValue(reflect.ValueOf(v), &missing)
+	if len(missing) > 0 {
+		return fmt.Errorf("Environment variables not set: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// expandValue walks the pointers, structs, lists and map values of `v` to the strings in them.
+func expandValue(v reflect.Value, missing *[]string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			expandValue(v.Elem(), missing)
+		}
+	case reflect.Struct:
+		for f := 0; f < v.NumField(); f++ {
+			if v.Field(f).CanSet() {
+				expandValue(v.Field(f), missing)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i), missing)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			expandValue(value, missing)
+			v.SetMapIndex(key, value)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandString(v.String(), missing))
+		}
+	}
+}
+
+// expandString replaces the ${ENV} variables in `s`, adding the ones that aren't set to `missing`.
+func expandString(s string, missing *[]string) string {
+	return envVariable.ReplaceAllStringFunc(s, func(variable string) string {
+		m := envVariable.FindStringSubmatch(variable)
+		if len(m[1]) == 0 {
+			return "${"
+		}
+		if value, ok := os.LookupEnv(m[1]); ok {
+			return value
+		}
+		if len(m[2]) > 0 {
+			return m[3]
+		}
+		*missing = append(*missing, m[1])
+		return variable
+	})
+}
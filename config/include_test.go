@@ -0,0 +1,111 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_LoadSettings_include(t *testing.T) {
+	// GIVEN a config that includes a folder of sites, with ${ENV} binds
+	os.Setenv("WEBD_TEST_BIND", "localhost:8101")
+	defer os.Unsetenv("WEBD_TEST_BIND")
+
+	// WHEN it's loaded
+	settings, err := LoadSettings("../test_data/include.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the included sites come after its own, with their paths relative to their own file
+	expected := []struct{ host, path string }{
+		{"example.com", "../example"},
+		{"files.example.com", "../test_data/files.example.com"},
+		{"test.example.com", "../test_data/test.example.com"},
+	}
+	if len(settings.Sites) != len(expected) {
+		t.Fatalf("Expecting %v sites got %v", len(expected), len(settings.Sites))
+	}
+	for s := range expected {
+		site := settings.Sites[s]
+		if site.Host != expected[s].host || site.Path != filepath.Clean(expected[s].path) {
+			t.Errorf("Expecting %v in %v got %v in %v", expected[s].host, expected[s].path, site.Host, site.Path)
+		}
+		// THEN the variables are expanded, and the acme settings apply to included sites too
+		if site.Bind.HTTP != "localhost:8101" {
+			t.Errorf("%v expecting localhost:8101 got %v", site.Host, site.Bind.HTTP)
+		}
+		if site.ACME.Email != "admin@example.com" {
+			t.Errorf("%v expecting admin@example.com got %v", site.Host, site.ACME.Email)
+		}
+	}
+
+	// THEN the included folder can be watched
+	if folders := settings.Include.Folders(); !reflect.DeepEqual(folders, []string{filepath.Clean("../test_data/sites.d")}) {
+		t.Errorf("Expecting the sites.d folder got %v", folders)
+	}
+}
+
+func Test_LoadSettings_include_errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "sites.yaml")
+	for _, files := range []map[string]string{
+		// an included file can only have sites
+		{"sites.yaml": "include: [more.yaml]\nsites: []\n", "more.yaml": "admin:\n  bind: :9100\n"},
+		// a bad glob
+		{"sites.yaml": "include: \"[\"\n"},
+		// a variable without a default that isn't set
+		{"sites.yaml": "- host: ${WEBD_TEST_NOT_SET}\n"},
+	} {
+		for name, data := range files {
+			if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err = LoadSettings(configFile); err == nil {
+			t.Errorf("Expecting an error for %v", files)
+		}
+	}
+}
+
+func Test_expandEnv(t *testing.T) {
+	os.Setenv("WEBD_TEST_HOST", "staging.example.com")
+	defer os.Unsetenv("WEBD_TEST_HOST")
+	os.Setenv("WEBD_TEST_INJECT", "x.example.com\n  path: /etc\n- host: evil.example.com # ")
+	defer os.Unsetenv("WEBD_TEST_INJECT")
+	type test struct {
+		in       string
+		expected string
+	}
+	tests := []test{
+		{"${WEBD_TEST_HOST}", "staging.example.com"},
+		{"${WEBD_TEST_HOST:-example.com}", "staging.example.com"},
+		{"${WEBD_TEST_NOT_SET:-example.com}", "example.com"},
+		{"${WEBD_TEST_NOT_SET:-}", ""},
+		{"script-src $self", "script-src $self"},
+		{"$${WEBD_TEST_HOST} ${WEBD_TEST_HOST}", "${WEBD_TEST_HOST} staging.example.com"},
+		{"${WEBD_TEST_INJECT}", "x.example.com\n  path: /etc\n- host: evil.example.com # "},
+	}
+	for _, tt := range tests {
+		// GIVEN a site with a variable in its host, its aliases and its headers
+		site := &Config{Host: tt.in, Aliases: []string{tt.in}, Headers: ConfigHeaders{Custom: []ConfigCustomHeader{{Set: map[string]string{"X": tt.in}}}}}
+
+		// WHEN it's expanded THEN each string is replaced, and stays one value
+		if err := expandEnv(&Settings{Sites: []*Config{site}}); err != nil {
+			t.Errorf("%v %v", tt.in, err)
+		} else if site.Host != tt.expected || site.Aliases[0] != tt.expected || site.Headers.Custom[0].Set["X"] != tt.expected {
+			t.Errorf("Expecting %q got %q %q %q", tt.expected, site.Host, site.Aliases[0], site.Headers.Custom[0].Set["X"])
+		}
+	}
+	if err := expandEnv(&Config{Host: "${WEBD_TEST_NOT_SET}", Path: "${WEBD_TEST_ALSO_NOT_SET}"}); err == nil {
+		t.Error("Expecting an error for variables that aren't set")
+	}
+}
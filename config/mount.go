@@ -0,0 +1,70 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConfigMount is more content served under a path prefix of a site's host, next to the site itself at /.
+// Like the site, it's templates and content in `path`, `static` files, or a `proxy`.
+//
+//	mounts:
+//	  - prefix: /downloads/
+//	    static: true
+//	    path: downloads
+//	  - prefix: /api/
+//	    strip: true
+//	    proxy: http://127.0.0.1:8080
+type ConfigMount struct {
+	Prefix       string // like /downloads/, the longest prefix that matches a request serves it
+	Strip        bool   // take the prefix off the path before a static or proxy mount sees it
+	Static       bool
+	Path         string
+	Proxy        ConfigProxy
+	LiveRefresh  bool
+	CacheControl string
+}
+
+// Root is the site itself as the mount at /.
+func (c *Config) Root() ConfigMount {
+	return ConfigMount{
+		Prefix:       "/",
+		Static:       c.Static,
+		Path:         c.Path,
+		Proxy:        c.Proxy,
+		LiveRefresh:  c.LiveRefresh,
+		CacheControl: c.CacheControl,
+	}
+}
+
+// checkMounts reports mounts without a prefix under /, the same prefix twice,
+// and `strip` on templates, their pages are always under the prefix.
+func checkMounts(c *Config) (problems []error) {
+	seen := make(map[string]bool)
+	for _, m := range c.Mounts {
+		prefix := CleanPrefix(m.Prefix)
+		if !strings.HasPrefix(m.Prefix, "/") || prefix == "/" {
+			problems = append(problems, fmt.Errorf("%v mount prefix %q has to be a path under /, like /downloads/", c.Host, m.Prefix))
+			continue
+		}
+		if seen[prefix] {
+			problems = append(problems, fmt.Errorf("%v mount prefix %v is used twice", c.Host, prefix))
+		}
+		seen[prefix] = true
+		if m.Strip && !m.Static && len(m.Proxy.Upstreams) == 0 {
+			problems = append(problems, fmt.Errorf("%v mount %v can't strip the prefix of templates", c.Host, prefix))
+		}
+	}
+	return
+}
+
+// CleanPrefix is `prefix` as a folder, /api is /api/.
+func CleanPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if len(prefix) == 0 {
+		return "/"
+	}
+	return "/" + prefix + "/"
+}
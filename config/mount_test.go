@@ -0,0 +1,53 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_checkMounts(t *testing.T) {
+	// GIVEN a site with good and bad mounts
+	site := &Config{
+		Host: "example.com",
+		Bind: ConfigBind{HTTP: ":80"},
+		Mounts: []ConfigMount{
+			{Prefix: "/downloads/", Static: true, Strip: true},
+			{Prefix: "/api", Strip: true, Proxy: ConfigProxy{Upstreams: []string{"http://127.0.0.1:8080"}}},
+			{Prefix: "/docs/"},
+			{Prefix: "/"},
+			{Prefix: "api/"},
+			{Prefix: "/api/"},
+			{Prefix: "/blog/", Strip: true},
+		},
+	}
+
+	// WHEN they're checked
+	problems := CheckSites([]*Config{site})
+
+	// THEN the bad ones are reported
+	expected := []string{
+		`example.com mount prefix "/" has to be a path under /`,
+		`example.com mount prefix "api/" has to be a path under /`,
+		"example.com mount prefix /api/ is used twice",
+		"example.com mount /blog/ can't strip the prefix of templates",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expecting %v problems got %v", len(expected), problems)
+	}
+	for p := range problems {
+		if !strings.HasPrefix(problems[p].Error(), expected[p]) {
+			t.Errorf("Expecting %q got %q", expected[p], problems[p])
+		}
+	}
+}
+
+func Test_CleanPrefix(t *testing.T) {
+	tests := map[string]string{"/": "/", "": "/", "/api": "/api/", "/api/": "/api/", "docs/v1": "/docs/v1/"}
+	for prefix, expected := range tests {
+		if got := CleanPrefix(prefix); got != expected {
+			t.Errorf("%q expecting %q got %q", prefix, expected, got)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import "time"
+
+// ConfigProxy sends a site's requests to the servers of an app, instead of serving files or templates.
+// It's either one upstream url or a mapping with the upstreams and options.
+//
+//	proxy: http://127.0.0.1:8080
+//
+//	proxy:
+//	  upstreams: [http://127.0.0.1:8080, http://127.0.0.1:8081]
+//	  healthcheck:
+//	    path: /health
+//	    interval: 10s
+//	  dialtimeout: 5s
+//	  responsetimeout: 30s
+type ConfigProxy struct {
+	Upstreams       []string // urls of the servers, requests take turns between the healthy ones
+	HealthCheck     ConfigHealthCheck
+	DialTimeout     time.Duration // connecting to an upstream, 10s by default
+	ResponseTimeout time.Duration // waiting for an upstream's response header, 0 waits forever
+	IdleTimeout     time.Duration // keeping an unused connection to an upstream open, 90s by default
+}
+
+// ConfigHealthCheck is how often upstreams are asked if they're working, an upstream that isn't gets no requests.
+type ConfigHealthCheck struct {
+	Path     string        // asked for with GET, any status under 400 is healthy, empty doesn't check
+	Interval time.Duration // between checks, 10s by default
+	Timeout  time.Duration // for an answer, 5s by default
+}
+
+// UnmarshalYAML allows a single upstream url as well as the mapping.
+func (p *ConfigProxy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var upstream string
+	if err := unmarshal(&upstream); err == nil {
+		*p = ConfigProxy{Upstreams: []string{upstream}}
+		return nil
+	}
+	type plain ConfigProxy
+	return unmarshal((*plain)(p))
+}
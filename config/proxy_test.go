@@ -0,0 +1,51 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package config
+
+import (
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ConfigProxy_UnmarshalYAML(t *testing.T) {
+	// GIVEN a site with one upstream, and one with the whole mapping
+	data := []byte(`
+- host: app.example.com
+  proxy: http://127.0.0.1:8080
+- host: api.example.com
+  proxy:
+    upstreams: [http://127.0.0.1:8080, http://127.0.0.1:8081]
+    healthcheck:
+      path: /health
+      interval: 15s
+    dialtimeout: 5s
+    responsetimeout: 1m
+`)
+
+	// WHEN they're loaded
+	var sites []*Config
+	if err := yaml.UnmarshalStrict(data, &sites); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN both have their upstreams, and the durations are parsed
+	if got := sites[0].Proxy; !reflect.DeepEqual(got, ConfigProxy{Upstreams: []string{"http://127.0.0.1:8080"}}) {
+		t.Errorf("Expecting the one upstream got %+v", got)
+	}
+	expected := ConfigProxy{
+		Upstreams:       []string{"http://127.0.0.1:8080", "http://127.0.0.1:8081"},
+		HealthCheck:     ConfigHealthCheck{Path: "/health", Interval: 15 * time.Second},
+		DialTimeout:     5 * time.Second,
+		ResponseTimeout: time.Minute,
+	}
+	if got := sites[1].Proxy; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expecting %+v got %+v", expected, got)
+	}
+
+	// GIVEN an unknown key THEN it's still an error
+	if err := yaml.UnmarshalStrict([]byte("proxy: {upstream: http://127.0.0.1}"), &Config{}); err == nil {
+		t.Error("Expecting an error for an unknown proxy key")
+	}
+}
@@ -22,7 +22,7 @@ func Test_Handler_content(t *testing.T) {
 	// GIVEN the index page has been shown
 	hostname, _ := url.Parse("http://test")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	s, _ := site.New(hostname, _testPath, false, false, testLog, testLog)
+	s, _ := site.New(hostname, _testPath, false, testLog, testLog)
 	req := httptest.NewRequest("GET", hostname.String()+"/", nil)
 	w := httptest.NewRecorder()
 	s.ServeHTTP(w, req)
@@ -77,7 +77,7 @@ func Test_HTML_rightness(t *testing.T) {
 	// GIVEN the index page is shown
 	hostname, _ := url.Parse("https://test")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	s, _ := site.New(hostname, _testPath, false, false, testLog, testLog)
+	s, _ := site.New(hostname, _testPath, false, testLog, testLog)
 	req := httptest.NewRequest("GET", hostname.String()+"/", nil)
 	w := httptest.NewRecorder()
 	s.ServeHTTP(w, req)
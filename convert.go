@@ -0,0 +1,62 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// configCommand runs the `config` subcommands, only `convert` so far.
+func configCommand(args []string, infoLog, errorLog *log.Logger) {
+	if len(args) == 0 || args[0] != "convert" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config convert [flags] <sites file> [<output file>]\n", os.Args[0])
+		os.Exit(ExitConvertParam)
+	}
+	convertConfig(args[1:], infoLog, errorLog)
+}
+
+// convertConfig rewrites a sites file as YAML, JSON or TOML, by the extension of the output file or `-to`.
+func convertConfig(args []string, infoLog, errorLog *log.Logger) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := flags.String("to", "", "format to convert to when writing to stdout: yaml, json or toml")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s config convert [flags] <sites file> [<output file>]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "The formats are picked by the file extensions, .json, .toml, or yaml for anything else.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 || (flags.NArg() == 1 && len(*to) == 0) {
+		flags.Usage()
+		os.Exit(ExitConvertParam)
+	}
+	in, out := flags.Arg(0), flags.Arg(1)
+	format := *to
+	if len(out) > 0 && len(format) == 0 {
+		format = config.Format(out)
+	}
+
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		errorLog.Println(err)
+		os.Exit(ExitConvertParam)
+	}
+	converted, err := config.Convert(data, config.Format(in), format)
+	if err != nil {
+		errorLog.Println(in, err)
+		os.Exit(ExitConvert)
+	}
+	if len(out) == 0 {
+		os.Stdout.Write(converted)
+		return
+	}
+	if err = ioutil.WriteFile(out, converted, 0644); err != nil {
+		errorLog.Println(err)
+		os.Exit(ExitConvert)
+	}
+	infoLog.Println("converted", in, "to", out)
+}
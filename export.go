@@ -0,0 +1,86 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/site"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportSite renders a site to plain files, for hosting on object storage.
+// The site is either a folder with `layouts` and `content`, or a host in a sites.yaml file.
+func exportSite(args []string, infoLog, errorLog *log.Logger) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	out := flags.String("out", "public", "folder to export the site into")
+	baseFlag := flags.String("base", "http://localhost", "base url of a site folder, sites.yaml uses the host")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s export [flags] <site folder>\n       %s export [flags] <sites.yaml> <host>\n\n", os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(ExitExportParam)
+	}
+
+	sitePath := flags.Arg(0)
+	base, err := url.Parse(*baseFlag)
+	if err != nil {
+		errorLog.Println(err)
+		os.Exit(ExitExportParam)
+	}
+	if info, err := os.Stat(sitePath); err == nil && !info.IsDir() {
+		if sitePath, base, err = exportConfig(sitePath, flags.Arg(1)); err != nil {
+			errorLog.Println(err)
+			os.Exit(ExitExportParam)
+		}
+	}
+
+	s, err := site.New(base, sitePath, false, infoLog, errorLog)
+	if err != nil {
+		errorLog.Println(err)
+		os.Exit(ExitExportParam)
+	}
+	infoLog.Println("exporting", base, "to", *out)
+	if err = s.Export(*out); err != nil {
+		errorLog.Println(err)
+		os.Exit(ExitExport)
+	}
+}
+
+// exportConfig finds the path and base url of `host` in a sites.yaml file.
+func exportConfig(configFile, host string) (sitePath string, base *url.URL, err error) {
+	sites, err := config.Load(configFile)
+	if err != nil {
+		return
+	}
+	for s := range sites {
+		cfg := sites[s]
+		if cfg.Host != host {
+			continue
+		}
+		if cfg.Static {
+			err = fmt.Errorf("%v is a static site, it can be copied from %v", host, cfg.Path)
+			return
+		}
+		if len(cfg.Proxy.Upstreams) > 0 {
+			err = fmt.Errorf("%v is a proxy site, its pages are made by %v", host, strings.Join(cfg.Proxy.Upstreams, ", "))
+			return
+		}
+		proto := "http"
+		if len(cfg.Bind.HTTPS) > 0 {
+			proto = "https"
+		}
+		base, err = url.Parse(proto + "://" + cfg.Host)
+		return filepath.Clean(cfg.Path), base, err
+	}
+	err = fmt.Errorf("Host %q not found in %v", host, configFile)
+	return
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+// Package to send a site's security headers, like HSTS and Content-Security-Policy, with every response.
+package headers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// NoncePlaceholder in a header value is replaced with a new nonce for every request, like in
+//
+//	csp: "script-src 'self' 'nonce-{nonce}'"
+const NoncePlaceholder = "{nonce}"
+
+type nonceKey struct{}
+
+// Handler sets the headers before `next` writes anything, so they're on its errors and redirects too.
+type Handler struct {
+	next   http.Handler
+	header http.Header // sent on every response
+	hsts   string      // Strict-Transport-Security, only sent over https
+	custom []config.ConfigCustomHeader
+	nonce  bool // some header has the NoncePlaceholder
+}
+
+// New wraps `next` so its responses have the headers in `cfg`.
+func New(next http.Handler, cfg config.ConfigHeaders) (*Handler, error) {
+	if len(cfg.ContentTypeOptions) > 0 && !strings.EqualFold(cfg.ContentTypeOptions, "nosniff") {
+		return nil, fmt.Errorf("Unknown contenttypeoptions %q, expecting nosniff", cfg.ContentTypeOptions)
+	}
+	for c := range cfg.Custom {
+		pattern := strings.TrimSuffix(cfg.Custom[c].Path, "/")
+		if _, err := path.Match(pattern, "/"); err != nil || len(cfg.Custom[c].Path) == 0 {
+			return nil, fmt.Errorf("Bad custom header path %q, expecting a glob like /static/*.css", cfg.Custom[c].Path)
+		}
+	}
+	h := &Handler{
+		next:   next,
+		header: make(http.Header),
+		hsts:   hsts(cfg.HSTS),
+		custom: cfg.Custom,
+	}
+	for name, value := range map[string]string{
+		"Content-Security-Policy": cfg.CSP,
+		"X-Frame-Options":         cfg.FrameOptions,
+		"Referrer-Policy":         cfg.ReferrerPolicy,
+		"Permissions-Policy":      cfg.PermissionsPolicy,
+		"X-Content-Type-Options":  cfg.ContentTypeOptions,
+	} {
+		if len(value) > 0 {
+			h.header.Set(name, value)
+			h.nonce = h.nonce || strings.Contains(value, NoncePlaceholder)
+		}
+	}
+	for c := range cfg.Custom {
+		for _, value := range cfg.Custom[c].Set {
+			h.nonce = h.nonce || strings.Contains(value, NoncePlaceholder)
+		}
+	}
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+	for name, values := range h.header {
+		header[name] = append([]string(nil), values...)
+	}
+	if req.TLS != nil && len(h.hsts) > 0 {
+		header.Set("Strict-Transport-Security", h.hsts)
+	}
+	for c := range h.custom {
+		if matchPath(h.custom[c].Path, req.URL.Path) {
+			for name, value := range h.custom[c].Set {
+				header.Set(name, value)
+			}
+		}
+	}
+	if h.nonce {
+		nonce, err := newNonce()
+		if err != nil {
+			http.Error(w, "Nonce Error", http.StatusInternalServerError)
+			return
+		}
+		for _, values := range header {
+			for v := range values {
+				values[v] = strings.Replace(values[v], NoncePlaceholder, nonce, -1)
+			}
+		}
+		req = req.WithContext(context.WithValue(req.Context(), nonceKey{}, nonce))
+	}
+	h.next.ServeHTTP(w, req)
+}
+
+// Nonce is the nonce that was put in the headers of the response to `req`, or empty if none are.
+// Inline scripts and styles need it in their nonce attribute.
+func Nonce(req *http.Request) string {
+	nonce, _ := req.Context().Value(nonceKey{}).(string)
+	return nonce
+}
+
+// newNonce is 128 random bits, base64url encoded so templates don't escape any of it.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hsts is the Strict-Transport-Security value, or empty without a max age.
+func hsts(cfg config.ConfigHSTS) string {
+	if cfg.MaxAge <= 0 {
+		return ""
+	}
+	value := "max-age=" + strconv.Itoa(cfg.MaxAge)
+	if cfg.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if cfg.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// matchPath is a path.Match of `urlPath`, a `pattern` ending in / matches everything under it.
+func matchPath(pattern, urlPath string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(urlPath, pattern)
+	}
+	ok, _ := path.Match(pattern, urlPath)
+	return ok
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package headers
+
+import (
+	"crypto/tls"
+	"github.com/robert-wallis/webd/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testHandler(t *testing.T, cfg config.ConfigHeaders) *Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/missing":
+			http.NotFound(w, req)
+		case "/moved":
+			http.Redirect(w, req, "/", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte("hello"))
+		}
+	})
+	h, err := New(next, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func Test_Handler_policy(t *testing.T) {
+	// GIVEN a site with every security header
+	h := testHandler(t, config.ConfigHeaders{
+		HSTS:               config.ConfigHSTS{MaxAge: 31536000, IncludeSubDomains: true, Preload: true},
+		CSP:                "default-src 'self'",
+		FrameOptions:       "DENY",
+		ReferrerPolicy:     "no-referrer",
+		PermissionsPolicy:  "camera=()",
+		ContentTypeOptions: "nosniff",
+	})
+	expected := map[string]string{
+		"Content-Security-Policy": "default-src 'self'",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Permissions-Policy":      "camera=()",
+		"X-Content-Type-Options":  "nosniff",
+	}
+
+	for _, path := range []string{"/", "/missing", "/moved"} {
+		// WHEN any response is served over http
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+path, nil))
+
+		// THEN it has the headers, but not HSTS
+		for name, value := range expected {
+			if got := w.Header().Get(name); got != value {
+				t.Errorf("%v %v expecting %q got %q", path, name, value, got)
+			}
+		}
+		if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+			t.Errorf("%v HSTS over http %q", path, got)
+		}
+	}
+
+	// WHEN served over https
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	// THEN HSTS is sent
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=31536000; includeSubDomains; preload" {
+		t.Errorf("HSTS got %q", got)
+	}
+}
+
+func Test_Handler_custom(t *testing.T) {
+	// GIVEN custom headers by path
+	h := testHandler(t, config.ConfigHeaders{
+		FrameOptions: "DENY",
+		Custom: []config.ConfigCustomHeader{
+			{Path: "/static/*.css", Set: map[string]string{"Cache-Control": "max-age=60"}},
+			{Path: "/embed/", Set: map[string]string{"X-Frame-Options": "SAMEORIGIN"}},
+		},
+	})
+	type test struct {
+		path   string
+		name   string
+		header string
+	}
+	tests := []test{
+		{"/static/site.css", "Cache-Control", "max-age=60"},
+		{"/static/site.js", "Cache-Control", ""},
+		{"/static/css/site.css", "Cache-Control", ""},
+		{"/embed/video/1", "X-Frame-Options", "SAMEORIGIN"},
+		{"/embedded", "X-Frame-Options", "DENY"},
+	}
+	for _, tt := range tests {
+		// WHEN the path is requested
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+
+		// THEN only matching paths get the custom headers
+		if got := w.Header().Get(tt.name); got != tt.header {
+			t.Errorf("%v %v expecting %q got %q", tt.path, tt.name, tt.header, got)
+		}
+	}
+}
+
+func Test_New_errors(t *testing.T) {
+	for _, cfg := range []config.ConfigHeaders{
+		{ContentTypeOptions: "sniff"},
+		{Custom: []config.ConfigCustomHeader{{Path: "/[", Set: map[string]string{"X": "y"}}}},
+		{Custom: []config.ConfigCustomHeader{{Set: map[string]string{"X": "y"}}}},
+	} {
+		if _, err := New(http.NotFoundHandler(), cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func Test_Handler_nonce(t *testing.T) {
+	// GIVEN a CSP with a nonce
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = Nonce(req)
+	})
+	h, err := New(next, config.ConfigHeaders{CSP: "script-src 'nonce-" + NoncePlaceholder + "'"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonces := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		// WHEN a request is served
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/", nil))
+
+		// THEN the site gets the same nonce as the header, and it's new every time
+		if len(seen) == 0 || w.Header().Get("Content-Security-Policy") != "script-src 'nonce-"+seen+"'" {
+			t.Errorf("nonce %q header %q", seen, w.Header().Get("Content-Security-Policy"))
+		}
+		if nonces[seen] {
+			t.Errorf("nonce %q used twice", seen)
+		}
+		nonces[seen] = true
+	}
+
+	// WHEN the CSP has no nonce
+	if h, err = New(next, config.ConfigHeaders{CSP: "default-src 'self'"}); err != nil {
+		t.Fatal(err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil))
+
+	// THEN there isn't one
+	if seen != "" {
+		t.Errorf("unexpected nonce %q", seen)
+	}
+}
@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/robert-wallis/webd/multisite"
@@ -15,14 +16,16 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const VERSION = "2018-01-28"
 
 var _bind = flag.String("bind", ":80", "bind ip and port")
 var _hostname = flag.String("hostname", "example.com", "outside hostname for site")
-var _liveRefresh = flag.Bool("live-refresh", false, "Should reload all templates each request?")
+var _liveRefresh = flag.Bool("live-refresh", false, "Should reload templates and content when their files change?")
 var _autoCert = flag.Bool("auto-cert", true, "Automatically get and renew TLS/SSL certificates?")
+var _shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "how long requests in flight get to finish after SIGINT or SIGTERM")
 
 const (
 	ExitSingleSiteInit = iota
@@ -30,11 +33,26 @@ const (
 	ExitSingleSiteRuntime
 	ExitMultiSiteInit
 	ExitMultiSiteRuntime
+	ExitExportParam
+	ExitExport
+	ExitShutdownTimeout
+	ExitCertsParam
+	ExitCertsRenew
+	ExitCheckParam
+	ExitCheck
+	ExitConvertParam
+	ExitConvert
 )
 
 func init() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\nVersion %s\n\n", os.Args[0], VERSION)
+		fmt.Fprintf(os.Stderr, "  %s [flags]              serve the site in the current folder\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s [flags] sites.yaml   serve every site in sites.yaml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s export ...           render a site to static files\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s certs ...            list or renew the certificates of sites.yaml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s check sites.yaml     report every problem in sites.yaml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s config convert ...   rewrite sites.yaml as json or toml, or back\n\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 }
@@ -45,10 +63,19 @@ func main() {
 	errorLog := log.New(os.Stderr, "", log.LstdFlags)
 	basePath := filepath.Base(os.Args[0])
 
-	if flag.NArg() == 0 {
+	switch {
+	case flag.NArg() == 0:
 		infoLog.Println("Starting", basePath, VERSION, "Single Site Mode")
 		singleSite(infoLog, errorLog)
-	} else {
+	case flag.Arg(0) == "export":
+		exportSite(flag.Args()[1:], infoLog, errorLog)
+	case flag.Arg(0) == "certs":
+		listCerts(flag.Args()[1:], infoLog, errorLog)
+	case flag.Arg(0) == "check":
+		checkConfig(flag.Args()[1:], infoLog, errorLog)
+	case flag.Arg(0) == "config":
+		configCommand(flag.Args()[1:], infoLog, errorLog)
+	default:
 		infoLog.Println("Starting", basePath, VERSION, "Multiple Site Mode")
 		multiSite(flag.Arg(0), infoLog, errorLog)
 	}
@@ -61,26 +88,60 @@ func singleSite(infoLog, errorLog *log.Logger) {
 		os.Exit(ExitSingleParam)
 		return
 	}
-	s, err := site.New(base, ".", *_liveRefresh, false, infoLog, errorLog)
+	s, err := site.New(base, ".", *_liveRefresh, infoLog, errorLog)
 	if err != nil {
 		errorLog.Println(err)
 		os.Exit(ExitSingleSiteInit)
 		return
 	}
-	if err = http.ListenAndServe(*_bind, s); err != nil {
+	server := &http.Server{
+		Addr:     *_bind,
+		Handler:  s,
+		ErrorLog: errorLog,
+	}
+	err, shutdownErr := serveUntilSignal(server.ListenAndServe, server.Shutdown, nil, *_shutdownTimeout, infoLog, errorLog)
+	s.Close()
+	if err != nil {
 		errorLog.Printf("Server Error: %v\n", err)
 		os.Exit(ExitSingleSiteRuntime)
 	}
+	exitShutdown(shutdownErr, infoLog, errorLog)
 }
 
 func multiSite(siteConfigFile string, infoLog, errorLog *log.Logger) {
 	ms, err := multisite.New(siteConfigFile, *_autoCert, infoLog, errorLog)
 	if err != nil {
-		errorLog.Println(err)
+		logError(err, errorLog)
 		os.Exit(ExitMultiSiteInit)
 	}
-	if err = ms.ListenAndServe(); err != nil {
+	if err = ms.Watch(); err != nil {
+		errorLog.Println("Config changes won't be reloaded:", err)
+	}
+	upgrade := func() error {
+		p, err := ms.Upgrade()
+		if err != nil {
+			return err
+		}
+		infoLog.Println("new process", p.Pid, "is serving")
+		return p.Release()
+	}
+	err, shutdownErr := serveUntilSignal(ms.ListenAndServe, ms.Shutdown, upgrade, *_shutdownTimeout, infoLog, errorLog)
+	if err != nil {
 		errorLog.Println(err)
 		os.Exit(ExitMultiSiteRuntime)
 	}
+	exitShutdown(shutdownErr, infoLog, errorLog)
+}
+
+// exitShutdown exits with ExitShutdownTimeout if requests were cut off because they didn't finish in time.
+func exitShutdown(err error, infoLog, errorLog *log.Logger) {
+	switch err {
+	case nil:
+		infoLog.Println("stopped")
+	case context.DeadlineExceeded:
+		errorLog.Println("Shutdown timed out after", *_shutdownTimeout, "requests were cut off")
+		os.Exit(ExitShutdownTimeout)
+	default:
+		errorLog.Println("Shutdown Error:", err)
+	}
 }
@@ -0,0 +1,105 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Names of the handler label, what part of a site answered the request.
+const (
+	Page     = "page"
+	Static   = "static"
+	Redirect = "redirect"
+	Proxy    = "proxy"
+	NotFound = "404"
+)
+
+type handlerKey struct{}
+
+// Instrument wraps `next` so the requests it serves are counted and timed for `host`.
+// The handler label is `handler` unless the site calls SetHandler, and is always NotFound for a 404.
+func Instrument(next http.Handler, host, handler string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		name := new(string)
+		*name = handler
+		rw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), handlerKey{}, name)))
+		if rw.status == http.StatusNotFound {
+			*name = NotFound
+		}
+		class := statusClass(rw.status)
+		RequestsTotal.Inc(host, class, *name)
+		RequestDuration.Observe(time.Since(start).Seconds(), host, class, *name)
+	})
+}
+
+// SetHandler labels the request with the part of the site that is answering it, like Page or Redirect.
+// It does nothing if the request isn't instrumented.
+func SetHandler(req *http.Request, handler string) {
+	if name, ok := req.Context().Value(handlerKey{}).(*string); ok {
+		*name = handler
+	}
+}
+
+// Handler serves the Default registry, for Prometheus to scrape.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.WriteTo(w)
+	})
+}
+
+// statusClass groups the status code by its first digit, like "2xx".
+func statusClass(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// statusWriter remembers the status of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush passes through to the real writer, for streaming responses.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the real writer, for upgraded connections like WebSockets.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("metrics: %T can't be hijacked", w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Instrument(t *testing.T) {
+	// GIVEN an instrumented handler that redirects, 404s or serves a page
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/old":
+			SetHandler(req, Redirect)
+			http.Redirect(w, req, "/new", http.StatusMovedPermanently)
+		case "/missing":
+			http.NotFound(w, req)
+		default:
+			w.Write([]byte("hello"))
+		}
+	})
+	h := Instrument(next, "instrument.example.com", Page)
+
+	// WHEN requests are served
+	for _, path := range []string{"/", "/", "/old", "/missing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// THEN they are counted by status class and handler
+	tests := []struct {
+		code, handler string
+		expected      float64
+	}{
+		{"2xx", Page, 2},
+		{"3xx", Redirect, 1},
+		{"4xx", NotFound, 1},
+	}
+	for _, tst := range tests {
+		if got := RequestsTotal.Get("instrument.example.com", tst.code, tst.handler); got != tst.expected {
+			t.Errorf("%v %v expecting %v got %v", tst.code, tst.handler, tst.expected, got)
+		}
+	}
+	if got := RequestDuration.Count("instrument.example.com", "2xx", Page); got != 2 {
+		t.Errorf("Expecting 2 timed requests got %v", got)
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	// GIVEN a counted request
+	TemplateErrors.Inc("handler.example.com")
+
+	// WHEN the metrics are scraped
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	// THEN it's in the Prometheus text format
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Expecting the Prometheus content type got %v", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `webd_template_errors_total{host="handler.example.com"} 1`) {
+		t.Errorf("Expecting the template error in\n%v", w.Body.String())
+	}
+}
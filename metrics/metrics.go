@@ -0,0 +1,328 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+// Package to count what the server is doing, and show it in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry is a list of metrics that are written out together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// metric is a family of series that share a name, like a counter with different labels.
+type metric interface {
+	write(w io.Writer)
+}
+
+// Default is the registry the webd metrics are in, and that Handler serves.
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (n int64, err error) {
+	r.mu.Lock()
+	metrics := r.metrics
+	r.mu.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for m := range metrics {
+		metrics[m].write(cw)
+	}
+	err = cw.w.(*bufio.Writer).Flush()
+	return cw.n, err
+}
+
+// vec holds a value for each combination of label values.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	series map[string]interface{}
+	values map[string][]string
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]interface{}),
+		values: make(map[string][]string),
+	}
+}
+
+// with returns the series for the label values, making it with `create` the first time.
+func (v *vec) with(values []string, create func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %v expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = create()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// get returns the series for the label values, or false if it was never written, reading doesn't make one.
+func (v *vec) get(values []string) (interface{}, bool) {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %v expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[strings.Join(values, "\xff")]
+	return s, ok
+}
+
+// sortedKeys lists the series in a stable order, so the output doesn't jump around.
+func (v *vec) sortedKeys() (keys []string) {
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func (v *vec) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+// labelString is {name="value",...} for the series, with any extra label on the end.
+func (v *vec) labelString(values []string, extraName, extraValue string) string {
+	var pairs []string
+	for l := range v.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, v.labels[l], escapeLabel(values[l])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, escapeLabel(extraValue)))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// value is a number that can be changed from many goroutines.
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (c *value) add(delta float64) {
+	c.mu.Lock()
+	c.v += delta
+	c.mu.Unlock()
+}
+
+func (c *value) set(v float64) {
+	c.mu.Lock()
+	c.v = v
+	c.mu.Unlock()
+}
+
+func (c *value) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.v
+}
+
+// CounterVec is a count that only goes up, like requests served.
+type CounterVec struct {
+	*vec
+}
+
+// NewCounterVec adds a counter to the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels)}
+	Default.register(c)
+	return c
+}
+
+// Inc adds one to the counter with the label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds `delta` to the counter with the label values.
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.with(values, func() interface{} { return &value{} }).(*value).add(delta)
+}
+
+// Get is the current count with the label values, mostly for tests.
+func (c *CounterVec) Get(values ...string) float64 {
+	if s, ok := c.get(values); ok {
+		return s.(*value).get()
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	writeValues(w, c.vec)
+}
+
+// GaugeVec is a value that can go up and down, or be set to a time.
+type GaugeVec struct {
+	*vec
+}
+
+// NewGaugeVec adds a gauge to the Default registry.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels)}
+	Default.register(g)
+	return g
+}
+
+// Set changes the gauge with the label values.
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.with(values, func() interface{} { return &value{} }).(*value).set(v)
+}
+
+// Get is the current value with the label values, mostly for tests.
+func (g *GaugeVec) Get(values ...string) float64 {
+	if s, ok := g.get(values); ok {
+		return s.(*value).get()
+	}
+	return 0
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	writeValues(w, g.vec)
+}
+
+func writeValues(w io.Writer, v *vec) {
+	v.header(w)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := v.sortedKeys()
+	for k := range keys {
+		s := v.series[keys[k]].(*value)
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelString(v.values[keys[k]], "", ""), formatFloat(s.get()))
+	}
+}
+
+// HistogramVec counts observations, like request durations, into buckets.
+type HistogramVec struct {
+	*vec
+	buckets []float64
+}
+
+// DefaultBuckets are for request durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec adds a histogram with the upper bounds in `buckets` to the Default registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{newVec(name, help, "histogram", labels), buckets}
+	Default.register(h)
+	return h
+}
+
+// Observe adds `v` to the histogram with the label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	s := h.with(values, func() interface{} { return &histogram{counts: make([]uint64, len(h.buckets))} }).(*histogram)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for b := range h.buckets {
+		if v <= h.buckets[b] {
+			s.counts[b]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count is how many observations were made with the label values, mostly for tests.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	series, ok := h.get(values)
+	if !ok {
+		return 0
+	}
+	s := series.(*histogram)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := h.sortedKeys()
+	for k := range keys {
+		s := h.series[keys[k]].(*histogram)
+		values := h.values[keys[k]]
+		s.mu.Lock()
+		var cumulative uint64
+		for b := range h.buckets {
+			cumulative += s.counts[b]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", formatFloat(h.buckets[b])), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(values, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(values, "", ""), s.count)
+		s.mu.Unlock()
+	}
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
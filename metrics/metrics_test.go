@@ -0,0 +1,101 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_CounterVec(t *testing.T) {
+	// GIVEN a counter
+	c := NewCounterVec("test_counter_total", "A test \\ counter.", "host")
+
+	// WHEN it's incremented for two hosts
+	c.Inc("b.example.com")
+	c.Inc("a.example.com")
+	c.Add(2, "a.example.com")
+
+	// THEN each host has its own count
+	if got := c.Get("a.example.com"); got != 3 {
+		t.Errorf("Expecting 3 got %v", got)
+	}
+	if got := c.Get("never.example.com"); got != 0 {
+		t.Errorf("Expecting 0 got %v", got)
+	}
+
+	// THEN the output is sorted by label, without the series that was only read
+	out := &bytes.Buffer{}
+	c.write(out)
+	expected := `# HELP test_counter_total A test \\ counter.
+# TYPE test_counter_total counter
+test_counter_total{host="a.example.com"} 3
+test_counter_total{host="b.example.com"} 1
+`
+	if out.String() != expected {
+		t.Errorf("Expecting\n%v\ngot\n%v", expected, out.String())
+	}
+}
+
+func Test_GaugeVec_escape(t *testing.T) {
+	// GIVEN a gauge with a label that needs escaping
+	g := NewGaugeVec("test_gauge", "A test gauge.", "host")
+	g.Set(1.5, "quote\"new\nline")
+
+	// WHEN it's written
+	out := &bytes.Buffer{}
+	g.write(out)
+
+	// THEN the label is escaped
+	if !strings.Contains(out.String(), `test_gauge{host="quote\"new\nline"} 1.5`) {
+		t.Errorf("Expecting an escaped label got\n%v", out.String())
+	}
+}
+
+func Test_HistogramVec(t *testing.T) {
+	// GIVEN a histogram
+	h := NewHistogramVec("test_seconds", "A test histogram.", []float64{0.1, 1}, "host")
+
+	// WHEN values are observed
+	h.Observe(0.05, "example.com")
+	h.Observe(0.5, "example.com")
+	h.Observe(5, "example.com")
+
+	// THEN the buckets are cumulative
+	out := &bytes.Buffer{}
+	h.write(out)
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{host="example.com",le="0.1"} 1
+test_seconds_bucket{host="example.com",le="1"} 2
+test_seconds_bucket{host="example.com",le="+Inf"} 3
+test_seconds_sum{host="example.com"} 5.55
+test_seconds_count{host="example.com"} 3
+`
+	if out.String() != expected {
+		t.Errorf("Expecting\n%v\ngot\n%v", expected, out.String())
+	}
+}
+
+func Test_Registry_WriteTo(t *testing.T) {
+	// GIVEN the default registry
+	NewCounterVec("test_registry_total", "Registered.").Inc()
+
+	// WHEN it's written
+	out := &bytes.Buffer{}
+	n, err := Default.WriteTo(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN it has the webd metrics and the new one
+	if n != int64(out.Len()) {
+		t.Errorf("Expecting %v bytes written got %v", out.Len(), n)
+	}
+	for _, name := range []string{"webd_http_requests_total", "webd_certificate_expiry_timestamp_seconds", "test_registry_total 1"} {
+		if !strings.Contains(out.String(), name) {
+			t.Errorf("Expecting %v in\n%v", name, out.String())
+		}
+	}
+}
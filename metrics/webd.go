@@ -0,0 +1,22 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package metrics
+
+// The metrics webd keeps about the sites it serves.
+var (
+	RequestsTotal = NewCounterVec("webd_http_requests_total",
+		"Requests served, by host, status class and handler.",
+		"host", "code", "handler")
+	RequestDuration = NewHistogramVec("webd_http_request_duration_seconds",
+		"Time to serve a request, by host, status class and handler.",
+		DefaultBuckets, "host", "code", "handler")
+	TemplateErrors = NewCounterVec("webd_template_errors_total",
+		"Pages that failed to render their template.",
+		"host")
+	ContentReloads = NewCounterVec("webd_content_reloads_total",
+		"Templates and content reloaded, by result success or failure.",
+		"host", "result")
+	CertificateExpiry = NewGaugeVec("webd_certificate_expiry_timestamp_seconds",
+		"When the TLS certificate served for a host expires, in unix time.",
+		"host")
+)
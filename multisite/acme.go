@@ -0,0 +1,96 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"strings"
+	"sync"
+)
+
+// defaultACMECache is where certificates are kept when the acme section doesn't say.
+const defaultACMECache = "autocert"
+
+// acmeManagers gets certificates automatically from the CA (Let's Encrypt) for `letsencrypt` sites.
+// Sites with the same acme settings share a manager, so the http bind of a host can answer the challenges for its https bind.
+type acmeManagers struct {
+	hostPolicy autocert.HostPolicy
+	mu         sync.Mutex
+	managers   map[config.ConfigACME]*autocert.Manager
+}
+
+func newACMEManagers(hostPolicy autocert.HostPolicy) *acmeManagers {
+	return &acmeManagers{
+		hostPolicy: hostPolicy,
+		managers:   make(map[config.ConfigACME]*autocert.Manager),
+	}
+}
+
+// manager returns the manager for the acme settings of a site, making it the first time they're seen.
+func (a *acmeManagers) manager(cfg config.ConfigACME) (*autocert.Manager, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if m, ok := a.managers[cfg]; ok {
+		return m, nil
+	}
+	if cfg.KeyType != "" && cfg.KeyType != config.KeyTypeECDSA && cfg.KeyType != config.KeyTypeRSA {
+		return nil, fmt.Errorf("Unknown acme keytype %q, expecting %v or %v", cfg.KeyType, config.KeyTypeECDSA, config.KeyTypeRSA)
+	}
+	cache := cfg.Cache
+	if len(cache) == 0 {
+		cache = defaultACMECache
+	}
+	m := &autocert.Manager{
+		Email:      cfg.Email,
+		Cache:      autocert.DirCache(cache),
+		Prompt:     acme.AcceptTOS,
+		HostPolicy: a.hostPolicy,
+	}
+	if len(cfg.Directory) > 0 {
+		m.Client = &acme.Client{DirectoryURL: cfg.Directory}
+	}
+	if len(cfg.EAB.KeyID) > 0 {
+		key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cfg.EAB.HMACKey, "="))
+		if err != nil {
+			return nil, fmt.Errorf("acme eab hmackey for %v isn't base64url: %v", cfg.EAB.KeyID, err)
+		}
+		m.ExternalAccountBinding = &acme.ExternalAccountBinding{KID: cfg.EAB.KeyID, Key: key}
+	}
+	a.managers[cfg] = m
+	return m, nil
+}
+
+// getACMECertificate gets the certificate from `m`, an RSA one if the site's keytype says so.
+func getACMECertificate(m *autocert.Manager, keyType string, hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if keyType == config.KeyTypeRSA {
+		// autocert picks ECDSA for any client that supports it, so only let it see RSA
+		rsaHello := *hello
+		rsaHello.SignatureSchemes = []tls.SignatureScheme{tls.PKCS1WithSHA256, tls.PSSWithSHA256}
+		rsaHello.CipherSuites = nil
+		hello = &rsaHello
+	}
+	return m.GetCertificate(hello)
+}
+
+// hostPolicy only allows certificates for the `letsencrypt` hosts currently served, so it follows config reloads.
+func (m *MultiSite) hostPolicy(_ context.Context, host string) error {
+	m.mu.Lock()
+	sites := m.sites
+	m.mu.Unlock()
+	host = strings.ToLower(host)
+	for s := range sites {
+		sites[s].mu.RLock()
+		r, ok := sites[s].hostMap[host]
+		sites[s].mu.RUnlock()
+		if ok && r.config.LetsEncrypt {
+			return nil
+		}
+	}
+	return fmt.Errorf("acme/autocert: host %q not configured for letsencrypt", host)
+}
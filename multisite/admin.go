@@ -0,0 +1,71 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"context"
+	"github.com/robert-wallis/webd/metrics"
+	"net/http"
+	"time"
+)
+
+// adminShutdownTimeout is how long a scrape gets to finish when the admin bind changes.
+const adminShutdownTimeout = 5 * time.Second
+
+// newAdminHandler serves the pages of the admin bind.
+func newAdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// startAdmin runs the admin server in the background, m.mu must be held.
+func (m *MultiSite) startAdmin() {
+	if len(m.adminBind) == 0 {
+		return
+	}
+	m.infoLog.Println("starting admin on", m.adminBind)
+	admin := &http.Server{
+		Addr:     m.adminBind,
+		Handler:  newAdminHandler(),
+		ErrorLog: m.errorLog,
+	}
+	l, err := listen(m.adminBind)
+	if err != nil {
+		m.errorLog.Println("Error: admin", m.adminBind, err)
+		return
+	}
+	m.admin = admin
+	m.adminListener = l
+	go func() {
+		if err := admin.Serve(l); err != http.ErrServerClosed {
+			m.errorLog.Println("Error: admin", admin.Addr, err)
+		}
+	}()
+}
+
+// stopAdmin shuts down the admin server if it's running, m.mu must be held.
+func (m *MultiSite) stopAdmin(ctx context.Context) {
+	if m.admin == nil {
+		return
+	}
+	if err := m.admin.Shutdown(ctx); err != nil {
+		m.errorLog.Println("Error: shutdown admin", m.admin.Addr, err)
+	}
+	m.admin = nil
+	m.adminListener = nil
+}
+
+// rebindAdmin moves the admin server when a reload changes its bind, m.mu must be held.
+func (m *MultiSite) rebindAdmin(bind string) {
+	if bind == m.adminBind {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+	defer cancel()
+	m.stopAdmin(ctx)
+	m.adminBind = bind
+	if m.serving {
+		m.startAdmin()
+	}
+}
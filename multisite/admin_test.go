@@ -0,0 +1,78 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"github.com/robert-wallis/webd/config"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_MultiSite_adminBind(t *testing.T) {
+	// GIVEN a config with an admin bind
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFilename := filepath.Join(dir, "sites.yaml")
+	data := "admin:\n  bind: localhost:8191\nsites: []\n"
+	if err = ioutil.WriteFile(configFilename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN it's loaded
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := New(configFilename, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the admin bind is known
+	if ms.adminBind != "localhost:8191" {
+		t.Errorf("Expecting localhost:8191 got %v", ms.adminBind)
+	}
+
+	// WHEN the bind is removed and the config is reloaded
+	writeTestConfig(t, configFilename, "files.example.com", "localhost:8101")
+	if err = ms.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN there is no admin bind
+	if ms.adminBind != "" {
+		t.Errorf("Expecting no admin bind got %v", ms.adminBind)
+	}
+}
+
+func Test_newAdminHandler_metrics(t *testing.T) {
+	// GIVEN a site that served a request
+	cfg := &config.Config{
+		Host:   "files.example.com",
+		Static: true,
+		Path:   "../test_data/files.example.com",
+	}
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	r, err := newRunningSite(nil, cfg, ":80", testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://files.example.com/files.example.com.txt", nil))
+
+	// WHEN the admin metrics are scraped
+	w := httptest.NewRecorder()
+	newAdminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	// THEN the request is counted for the host as static
+	expected := `webd_http_requests_total{host="files.example.com",code="2xx",handler="static"}`
+	if !strings.Contains(w.Body.String(), expected) {
+		t.Errorf("Expecting %v in\n%v", expected, w.Body.String())
+	}
+}
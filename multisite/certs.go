@@ -0,0 +1,124 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// certStore holds the certificates from files for the sites on a bind, by host name.
+type certStore struct {
+	hosts map[string]*tls.Certificate // exact names and wildcards like *.example.com
+	first *tls.Certificate            // for clients that don't send a server name
+}
+
+// loadCertStore reads the `cert`, `key` and `certdir` files of every site.
+// A site's own cert is used for its host and aliases, the rest are found by the names in the certificate.
+func loadCertStore(sites []*runningSite) (*certStore, error) {
+	store := &certStore{hosts: make(map[string]*tls.Certificate)}
+	for r := range sites {
+		cfg := sites[r].config
+		if len(cfg.CertDir) > 0 {
+			certs, err := loadCertDir(cfg.CertDir)
+			if err != nil {
+				return nil, err
+			}
+			for c := range certs {
+				store.add(certs[c], certNames(certs[c]))
+			}
+		}
+		if len(cfg.Cert) > 0 || len(cfg.Key) > 0 {
+			cert, err := loadCert(cfg.Cert, cfg.Key)
+			if err != nil {
+				return nil, err
+			}
+			store.add(cert, append(cfg.HostList(), certNames(cert)...))
+		}
+	}
+	return store, nil
+}
+
+// add indexes `cert` by `names`, without replacing a certificate that was added before.
+func (c *certStore) add(cert *tls.Certificate, names []string) {
+	if c.first == nil {
+		c.first = cert
+	}
+	for n := range names {
+		name := strings.ToLower(names[n])
+		if _, ok := c.hosts[name]; !ok {
+			c.hosts[name] = cert
+		}
+	}
+}
+
+// find returns the certificate for `host`, an exact match first, then a wildcard.
+func (c *certStore) find(host string) *tls.Certificate {
+	if c == nil {
+		return nil
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if len(host) == 0 {
+		return c.first
+	}
+	if cert, ok := c.hosts[host]; ok {
+		return cert
+	}
+	if dot := strings.IndexByte(host, '.'); dot > 0 {
+		if cert, ok := c.hosts["*"+host[dot:]]; ok {
+			return cert
+		}
+	}
+	return nil
+}
+
+// loadCert reads a PEM certificate and private key pair.
+func loadCert(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load certificate %v and key %v: %v", certFile, keyFile, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, fmt.Errorf("Couldn't parse certificate %v: %v", certFile, err)
+	}
+	return &cert, nil
+}
+
+// loadCertDir reads every `name.crt` or `name.pem` in `dir` that has a `name.key` next to it.
+func loadCertDir(dir string) (certs []*tls.Certificate, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read certificate folder %v: %v", dir, err)
+	}
+	for f := range files {
+		name := files[f].Name()
+		ext := filepath.Ext(name)
+		if files[f].IsDir() || (ext != ".crt" && ext != ".pem") {
+			continue
+		}
+		keyFile := filepath.Join(dir, strings.TrimSuffix(name, ext)+".key")
+		if _, err = os.Stat(keyFile); err != nil {
+			continue // a CA chain or some other certificate without a key
+		}
+		cert, err := loadCert(filepath.Join(dir, name), keyFile)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// certNames are the host names a certificate is valid for.
+func certNames(cert *tls.Certificate) []string {
+	names := append([]string{}, cert.Leaf.DNSNames...)
+	if len(names) == 0 && len(cert.Leaf.Subject.CommonName) > 0 {
+		names = append(names, cert.Leaf.Subject.CommonName)
+	}
+	return names
+}
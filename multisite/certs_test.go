@@ -0,0 +1,159 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/metrics"
+	"golang.org/x/crypto/acme/autocert"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert saves a self signed certificate for `names` as `dir`/`name`.crt and `dir`/`name`.key.
+func writeTestCert(t *testing.T, dir, name string, names ...string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func Test_serverSite_getCertificate(t *testing.T) {
+	// GIVEN a site with its own cert, and a folder of certs
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir, "internal", "internal.example.com")
+	pairs := filepath.Join(dir, "pairs")
+	os.Mkdir(pairs, 0755)
+	writeTestCert(t, pairs, "wild", "*.example.org")
+	writeTestCert(t, pairs, "other", "other.example.net")
+	ioutil.WriteFile(filepath.Join(pairs, "chain.pem"), []byte("no key"), 0644)
+	sites := []*runningSite{
+		{config: &config.Config{Host: "internal.example.com", Aliases: []string{"alias.example.com"}, Cert: certFile, Key: keyFile}},
+		{config: &config.Config{Host: "www.example.org", CertDir: pairs}},
+		{config: &config.Config{Host: "acme.example.com", LetsEncrypt: true}},
+	}
+	certs, err := loadCertStore(sites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &serverSite{bind: ":443", runningSites: sites, hostMap: make(map[string]*runningSite), certs: certs}
+	for r := range sites {
+		appendHostMap(s.hostMap, sites[r])
+	}
+
+	// WHEN certificates are asked for by server name
+	type test struct {
+		serverName string
+		expected   string
+	}
+	tests := []test{
+		{"internal.example.com", "internal.example.com"},
+		{"ALIAS.example.com", "internal.example.com"},
+		{"www.example.org", "*.example.org"},
+		{"other.example.net", "other.example.net"},
+		{"", "internal.example.com"},
+		{"acme.example.com", ""},
+		{"unknown.example.com", ""},
+	}
+	for i := range tests {
+		tst := tests[i]
+		cert, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: tst.serverName})
+
+		// THEN the certificate with the matching name should be chosen
+		if tst.expected == "" {
+			if err == nil {
+				t.Errorf("%q expecting no certificate got %v", tst.serverName, cert.Leaf.DNSNames)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tst.serverName, err)
+			continue
+		}
+		if cert.Leaf.DNSNames[0] != tst.expected {
+			t.Errorf("%q expecting %v got %v", tst.serverName, tst.expected, cert.Leaf.DNSNames)
+		}
+	}
+}
+
+func Test_loadCertStore_error(t *testing.T) {
+	// GIVEN a site with a missing key, WHEN the certs are loaded THEN it should error
+	sites := []*runningSite{{config: &config.Config{Host: "example.com", Cert: "noexist.crt", Key: "noexist.key"}}}
+	if _, err := loadCertStore(sites); err == nil {
+		t.Error("Expecting an error for a missing certificate")
+	}
+}
+
+func Test_serverSite_recordCertificates(t *testing.T) {
+	// GIVEN a TLS bind with a certificate file, and a letsencrypt host with one in the acme cache
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "expiry", "expiry.example.com")
+	cacheDir := filepath.Join(dir, "cache")
+	os.Mkdir(cacheDir, 0700)
+	acmeCert, acmeKey := writeTestCert(t, dir, "cached", "cached.example.com")
+	certPEM, _ := ioutil.ReadFile(acmeCert)
+	keyPEM, _ := ioutil.ReadFile(acmeKey)
+	ioutil.WriteFile(filepath.Join(cacheDir, "cached.example.com"), append(keyPEM, certPEM...), 0600)
+	acme := config.ConfigACME{Cache: cacheDir}
+	sites := []*runningSite{
+		{config: &config.Config{Host: "expiry.example.com", Cert: certFile, Key: keyFile}},
+		{config: &config.Config{Host: "cached.example.com", Aliases: []string{"uncached.example.com"}, LetsEncrypt: true, ACME: acme}, acManager: &autocert.Manager{}},
+	}
+	certs, err := loadCertStore(sites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &serverSite{bind: ":443", tlsEnabled: true}
+
+	// WHEN the sites are applied, before any handshake
+	s.recordCertificates(&siteUpdate{runningSites: sites, certs: certs})
+
+	// THEN the expiry of every certificate it has is known
+	for _, host := range []string{"expiry.example.com", "cached.example.com"} {
+		if expiry := metrics.CertificateExpiry.Get(host); expiry < float64(time.Now().Unix()) {
+			t.Errorf("%v expecting its expiry got %v", host, expiry)
+		}
+	}
+	if expiry := metrics.CertificateExpiry.Get("uncached.example.com"); expiry != 0 {
+		t.Errorf("Expecting no expiry without a certificate got %v", expiry)
+	}
+}
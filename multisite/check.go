@@ -0,0 +1,169 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"fmt"
+	"github.com/robert-wallis/webd/accesslog"
+	"github.com/robert-wallis/webd/compress"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/headers"
+	"github.com/robert-wallis/webd/proxy"
+	"github.com/robert-wallis/webd/site"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Problems are everything wrong with a config file, so they can all be fixed at once.
+type Problems []error
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i := range p {
+		lines[i] = p[i].Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check reports every problem in a config file that would stop New, or a site, from working.
+// Unknown keys, duplicate hosts, missing folders, layouts that don't parse, sites without a bind,
+// and https binds that can't serve TLS. The error is Problems, or nil if there are none.
+func Check(configFilename string) error {
+	settings, err := config.LoadSettings(configFilename)
+	if err != nil {
+		return Problems{err}
+	}
+	var problems Problems
+	problems = append(problems, config.CheckKeys(configFilename)...)
+	problems = append(problems, config.CheckSites(settings.Sites)...)
+	for s := range settings.Sites {
+		problems = append(problems, checkSite(settings.Sites[s])...)
+	}
+	problems = append(problems, checkAccessLogs(settings)...)
+	binds := config.GroupServers(settings.Sites)
+	for _, bind := range config.SortedBinds(binds) {
+		problems = append(problems, checkTLS(bind, binds[bind])...)
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// checkSite reports the problems of a site on its own, its folders, layouts, mounts and options.
+func checkSite(cfg *config.Config) (problems []error) {
+	problems = append(problems, checkMount(cfg.Host, cfg.Root())...)
+	for _, m := range cfg.Mounts {
+		problems = append(problems, checkMount(cfg.Host+config.CleanPrefix(m.Prefix), m)...)
+	}
+	if _, err := compress.New(http.NotFoundHandler(), cfg.Compress); err != nil {
+		problems = append(problems, fmt.Errorf("%v: %v", cfg.Host, err))
+	}
+	if _, err := headers.New(http.NotFoundHandler(), cfg.Headers); err != nil {
+		problems = append(problems, fmt.Errorf("%v: %v", cfg.Host, err))
+	}
+	if _, err := accesslog.New(http.NotFoundHandler(), nil, cfg.AccessLog.Format); err != nil {
+		problems = append(problems, fmt.Errorf("%v: %v", cfg.Host, err))
+	}
+	if cfg.LetsEncrypt {
+		if _, err := newACMEManagers(nil).manager(cfg.ACME); err != nil {
+			problems = append(problems, fmt.Errorf("%v: %v", cfg.Host, err))
+		}
+	}
+	return
+}
+
+// checkAccessLogs reports access logs that are rotated differently by the sites writing to them, and a bad server wide log.
+func checkAccessLogs(settings *config.Settings) (problems []error) {
+	if _, err := accesslog.New(http.NotFoundHandler(), nil, settings.AccessLog.Format); err != nil {
+		problems = append(problems, fmt.Errorf("accesslog: %v", err))
+	}
+	type user struct {
+		name string
+		cfg  config.ConfigAccessLog
+	}
+	users := []user{{"accesslog", settings.AccessLog}}
+	for _, cfg := range settings.Sites {
+		users = append(users, user{cfg.Host, cfg.AccessLog})
+	}
+	first := make(map[string]user)
+	for _, u := range users {
+		if len(u.cfg.Path) == 0 {
+			continue
+		}
+		path, err := filepath.Abs(u.cfg.Path)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%v: %v", u.name, err))
+			continue
+		}
+		f, ok := first[path]
+		if !ok {
+			first[path] = u
+		} else if f.cfg.MaxSize != u.cfg.MaxSize || f.cfg.MaxBackups != u.cfg.MaxBackups {
+			problems = append(problems, fmt.Errorf("%v: access log %v has a different maxsize or maxbackups in %v", u.name, u.cfg.Path, f.name))
+		}
+	}
+	return
+}
+
+// checkMount reports the problems of what serves a prefix of `name`, the folders and layouts, or the proxy.
+func checkMount(name string, m config.ConfigMount) (problems []error) {
+	if len(m.Proxy.Upstreams) > 0 {
+		if m.Static {
+			problems = append(problems, fmt.Errorf("%v: can't be static and a proxy", name))
+		}
+		if err := proxy.Check(m.Proxy); err != nil {
+			problems = append(problems, fmt.Errorf("%v: %v", name, err))
+		}
+	} else if m.Static {
+		if info, err := os.Stat(m.Path); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("%v: missing static folder %v", name, m.Path))
+		}
+	} else {
+		for _, err := range site.Check(m.Path) {
+			problems = append(problems, fmt.Errorf("%v: %v", name, err))
+		}
+	}
+	return
+}
+
+// checkTLS reports an https bind that can't work: one that's also an http bind, options the sites disagree on,
+// certificate files that don't load, and hosts without a certificate file or `letsencrypt`.
+func checkTLS(bind string, configs []*config.Config) (problems []error) {
+	isTLS, err := isTLSBind(bind, configs)
+	if err != nil || !isTLS {
+		if err != nil {
+			problems = append(problems, err)
+		}
+		return
+	}
+	opts, err := tlsOptions(bind, configs)
+	if err == nil {
+		_, err = newTLSConfig(bind, opts, nil)
+	}
+	if err != nil {
+		problems = append(problems, err)
+	}
+	sites := make([]*runningSite, len(configs))
+	for c := range configs {
+		sites[c] = &runningSite{config: configs[c]}
+	}
+	certs, err := loadCertStore(sites)
+	if err != nil {
+		return append(problems, err)
+	}
+	for c := range configs {
+		cfg := configs[c]
+		if cfg.Bind.HTTPS != bind || cfg.LetsEncrypt {
+			continue
+		}
+		for _, host := range cfg.HostList() {
+			if certs.find(host) == nil {
+				problems = append(problems, fmt.Errorf("%v has no certificate on %v, set letsencrypt, cert or certdir", host, bind))
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"github.com/robert-wallis/webd/config"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Check(t *testing.T) {
+	// GIVEN the test configs, THEN they're fine
+	for _, file := range []string{"../test_data/combine_sites.yaml", "../example/sites.yaml"} {
+		if err := Check(file); err != nil {
+			t.Errorf("%v %v", file, err)
+		}
+	}
+
+	// GIVEN a config with several problems
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secure, err := filepath.Abs("../test_data/secure.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFilename := filepath.Join(dir, "sites.yaml")
+	data := "- host: example.com\n  path: nowhere\n  bind:\n    http: localhost:8101\n    https: localhost:8101\n" +
+		"- host: secure.example.com\n  static: true\n  path: " + secure + "\n  bind:\n    https: localhost:8443\n" +
+		"- host: files.example.com\n  static: true\n  path: files\n  headers:\n    contenttypeoptions: sniff\n  bind:\n    http: localhost:8101\n"
+	if err = ioutil.WriteFile(configFilename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN it's checked
+	err = Check(configFilename)
+
+	// THEN they're all reported together
+	problems, ok := err.(Problems)
+	if !ok {
+		t.Fatalf("Expecting Problems got %v", err)
+	}
+	expected := []string{
+		"example.com: Missing layouts folder",
+		"example.com: Missing content folder",
+		"example.com: Missing static folder",
+		"files.example.com: missing static folder",
+		"files.example.com: Unknown contenttypeoptions",
+		"localhost:8101 is an https bind, and an http bind",
+		"secure.example.com has no certificate on localhost:8443",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expecting %v problems got %v", len(expected), err)
+	}
+	for p := range problems {
+		if !strings.HasPrefix(problems[p].Error(), expected[p]) {
+			t.Errorf("Expecting %q got %q", expected[p], problems[p])
+		}
+	}
+
+	// THEN New won't start it
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	if _, err = New(configFilename, false, testLog, testLog); err == nil {
+		t.Error("Expecting New to fail the check")
+	}
+}
+
+func Test_checkAccessLogs(t *testing.T) {
+	// GIVEN sites sharing access logs, one rotated differently
+	settings := &config.Settings{
+		AccessLog: config.ConfigAccessLog{Path: "logs/access.log", Format: "xml"},
+		Sites: []*config.Config{
+			{Host: "a.example.com", AccessLog: config.ConfigAccessLog{Path: "logs/shared.log", MaxSize: 10}},
+			{Host: "b.example.com", AccessLog: config.ConfigAccessLog{Path: "logs/shared.log", MaxSize: 10}},
+			{Host: "c.example.com", AccessLog: config.ConfigAccessLog{Path: "logs/shared.log", MaxSize: 20}},
+		},
+	}
+
+	// WHEN they're checked
+	problems := checkAccessLogs(settings)
+
+	// THEN the bad format and the different rotation are reported
+	expected := []string{
+		`accesslog: Unknown access log format "xml"`,
+		"c.example.com: access log logs/shared.log has a different maxsize or maxbackups in a.example.com",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expecting %v problems got %v", len(expected), problems)
+	}
+	for p := range problems {
+		if !strings.HasPrefix(problems[p].Error(), expected[p]) {
+			t.Errorf("Expecting %q got %q", expected[p], problems[p])
+		}
+	}
+}
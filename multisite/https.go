@@ -0,0 +1,54 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/metrics"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// httpsRedirect wraps the handler of a site on its http bind, to send browsers to its https bind.
+// Paths listed in `nohttpsredirect` are still served over http.
+// Sites with `letsencrypt: true` answer the http-01 challenges under /.well-known/acme-challenge/ first.
+func (r *runningSite) httpsRedirect(next http.Handler) http.Handler {
+	if !shouldRedirectHttps(r.config) || r.bind != r.config.Bind.HTTP {
+		return next
+	}
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if redirectExempt(r.config.NoHTTPSRedirect, req.URL.Path) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		u := *req.URL
+		u.Scheme = "https"
+		u.Host = httpsHost(r.config)
+		metrics.SetHandler(req, metrics.Redirect)
+		http.Redirect(w, req, u.String(), http.StatusMovedPermanently)
+	})
+	if r.acManager != nil {
+		handler = r.acManager.HTTPHandler(handler)
+	}
+	return handler
+}
+
+// httpsHost is the host of the site on its https bind, with the port unless it's 443.
+func httpsHost(cfg *config.Config) string {
+	_, port, err := net.SplitHostPort(cfg.Bind.HTTPS)
+	if err != nil || port == "" || port == "443" || port == "https" {
+		return cfg.Host
+	}
+	return net.JoinHostPort(cfg.Host, port)
+}
+
+// redirectExempt is true if `path` is one of the `exempt` paths, or is in a folder listed with a trailing slash.
+func redirectExempt(exempt []string, path string) bool {
+	for e := range exempt {
+		if path == exempt[e] || (strings.HasSuffix(exempt[e], "/") && strings.HasPrefix(path, exempt[e])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"context"
+	"github.com/robert-wallis/webd/config"
+	"log"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_runningSite_httpsRedirect(t *testing.T) {
+	// GIVEN a letsencrypt site on its http bind, with a path exempt from the redirect
+	cfg := &config.Config{
+		Host:            "files.example.com",
+		Static:          true,
+		Path:            "../test_data/files.example.com",
+		LetsEncrypt:     true,
+		NoHTTPSRedirect: []string{"/files.example.com.txt", "/plain/"},
+		Bind: config.ConfigBind{
+			HTTP:  ":80",
+			HTTPS: ":443",
+		},
+	}
+	ss := &serverSite{acme: newACMEManagers(nil)}
+	cfg.ACME.Cache = t.TempDir()
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	r, err := newRunningSite(ss, cfg, ":80", testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// WHEN requests come in over http
+	type test struct {
+		path     string
+		code     int
+		location string
+	}
+	tests := []test{
+		{"/files.example.com.txt", 200, ""},
+		{"/plain/nope", 404, ""},
+		{"/other?a=b", 301, "https://files.example.com/other?a=b"},
+		{"/.well-known/acme-challenge/token", 404, ""},
+	}
+	for i := range tests {
+		tst := tests[i]
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "http://files.example.com"+tst.path, nil))
+
+		// THEN only the exempt paths and the challenges aren't redirected
+		if w.Code != tst.code {
+			t.Errorf("%v expecting %v got %v", tst.path, tst.code, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != tst.location {
+			t.Errorf("%v expecting location %q got %q", tst.path, tst.location, location)
+		}
+	}
+
+	// WHEN its https bind isn't on 443 THEN the redirect keeps the port
+	other := *cfg
+	other.Bind.HTTPS = "localhost:8443"
+	r, err = newRunningSite(ss, &other, ":80", testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://files.example.com/other?a=b", nil))
+	if location := w.Header().Get("Location"); location != "https://files.example.com:8443/other?a=b" {
+		t.Errorf("Expecting the redirect to keep port 8443 got %q", location)
+	}
+
+	// WHEN the same site is on its https bind THEN it isn't redirected
+	r, err = newRunningSite(ss, cfg, ":443", testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://files.example.com/other", nil))
+	if w.Code != 404 {
+		t.Errorf("Expecting 404 on https got %v", w.Code)
+	}
+}
+
+func Test_MultiSite_hostPolicy(t *testing.T) {
+	// GIVEN a multi-site where only example.com has letsencrypt
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := New("../test_data/combine_sites.yaml", false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN certificates are asked for THEN only the letsencrypt hosts are allowed
+	for host, allowed := range map[string]bool{
+		"example.com":        true,
+		"www.example.com":    true,
+		"files.example.com":  false,
+		"nope.example.com":   false,
+		"secure.example.com": false,
+	} {
+		if err := ms.hostPolicy(context.Background(), host); (err == nil) != allowed {
+			t.Errorf("%v expecting allowed %v got %v", host, allowed, err)
+		}
+	}
+}
+
+func Test_acmeManagers_manager(t *testing.T) {
+	// GIVEN the acme settings of a few sites
+	managers := newACMEManagers(nil)
+	internal := config.ConfigACME{
+		Directory: "https://ca.internal/acme/directory",
+		Cache:     t.TempDir(),
+		EAB:       config.ConfigEAB{KeyID: "kid-1", HMACKey: "c2VjcmV0"},
+	}
+
+	// WHEN the managers are made
+	m, err := managers.manager(internal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN they use the settings, and are shared by sites with the same settings
+	if m.Client == nil || m.Client.DirectoryURL != internal.Directory {
+		t.Errorf("Expecting directory %v got %+v", internal.Directory, m.Client)
+	}
+	if m.ExternalAccountBinding == nil || m.ExternalAccountBinding.KID != "kid-1" || string(m.ExternalAccountBinding.Key) != "secret" {
+		t.Errorf("Expecting the eab key got %+v", m.ExternalAccountBinding)
+	}
+	if again, _ := managers.manager(internal); again != m {
+		t.Error("Expecting the same manager for the same settings")
+	}
+	if other, _ := managers.manager(config.ConfigACME{Cache: internal.Cache}); other == m || other.Client != nil {
+		t.Error("Expecting a Let's Encrypt manager for other settings")
+	}
+
+	// WHEN the settings are wrong THEN it should error
+	if _, err = managers.manager(config.ConfigACME{KeyType: "dsa"}); err == nil {
+		t.Error("Expecting an error for an unknown keytype")
+	}
+	if _, err = managers.manager(config.ConfigACME{EAB: config.ConfigEAB{KeyID: "kid", HMACKey: "not base64!"}}); err == nil {
+		t.Error("Expecting an error for an eab key that isn't base64url")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"golang.org/x/crypto/acme/autocert"
+	"log"
+	"strings"
+	"time"
+)
+
+// Sources of a CertInfo.
+const (
+	CertFromFile = "file"
+	CertFromACME = "acme"
+)
+
+// CertInfo is the certificate webd has for a host, from a file or the acme cache.
+type CertInfo struct {
+	Host     string    // the host name the certificate is for
+	Site     string    // the main host of the site
+	Source   string    // CertFromFile, CertFromACME, or empty when the host doesn't use TLS
+	Allowed  bool      // the host policy lets the acme CA issue a certificate for it
+	Cached   bool      // a certificate was found
+	Issuer   string    // who signed the certificate
+	Names    []string  // the names the certificate is valid for
+	NotAfter time.Time // when it expires
+	Err      error     // why the certificate couldn't be read
+}
+
+// Certificates lists the certificate of every host in the config file, without starting any servers.
+func Certificates(configFile string) (infos []*CertInfo, err error) {
+	sites, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	for c := range sites {
+		cfg := sites[c]
+		var certs *certStore
+		var certsErr error
+		if len(cfg.Cert) > 0 || len(cfg.CertDir) > 0 {
+			certs, certsErr = loadCertStore([]*runningSite{{config: cfg}})
+		}
+		hosts := cfg.HostList()
+		for h := range hosts {
+			info := &CertInfo{Host: hosts[h], Site: cfg.Host, Allowed: cfg.LetsEncrypt}
+			switch {
+			case certsErr != nil:
+				info.Source = CertFromFile
+				info.Err = certsErr
+			case certs.find(hosts[h]) != nil:
+				info.Source = CertFromFile
+				info.describe(certs.find(hosts[h]).Leaf)
+			case cfg.LetsEncrypt:
+				info.Source = CertFromACME
+				leaf, cacheErr := cachedCert(cfg.ACME, hosts[h])
+				if cacheErr != nil && cacheErr != autocert.ErrCacheMiss {
+					info.Err = cacheErr
+				} else if leaf != nil {
+					info.describe(leaf)
+				}
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// RenewCertificates gets a certificate for every `letsencrypt` host that doesn't have one, or that expires within `before`.
+// The http-01 challenges are put in the acme cache, so a running webd that shares the cache can answer them.
+func RenewCertificates(configFile string, before time.Duration, infoLog, errorLog *log.Logger) (err error) {
+	sites, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	allowed := make(map[string]bool)
+	for c := range sites {
+		if sites[c].LetsEncrypt {
+			for _, host := range sites[c].HostList() {
+				allowed[strings.ToLower(host)] = true
+			}
+		}
+	}
+	managers := newACMEManagers(func(_ context.Context, host string) error {
+		if !allowed[strings.ToLower(host)] {
+			return fmt.Errorf("acme/autocert: host %q not configured for letsencrypt", host)
+		}
+		return nil
+	})
+	var failed []string
+	for c := range sites {
+		cfg := sites[c]
+		if !cfg.LetsEncrypt {
+			continue
+		}
+		m, err := managers.manager(cfg.ACME)
+		if err != nil {
+			return err
+		}
+		m.HTTPHandler(nil) // lets the CA use http-01
+		for _, host := range cfg.HostList() {
+			leaf, _ := cachedCert(cfg.ACME, host)
+			if leaf != nil && time.Until(leaf.NotAfter) > before {
+				infoLog.Println("valid", host, "until", leaf.NotAfter.Format(time.RFC3339))
+				continue
+			}
+			infoLog.Println("renewing", host)
+			cache := m.Cache
+			if leaf != nil {
+				// autocert would return the cached one, it's replaced once the new one is issued
+				m.Cache = renewCache{Cache: cache, key: cacheKey(cfg.ACME, host)}
+			}
+			cert, err := getACMECertificate(m, cfg.ACME.KeyType, renewHello(host))
+			m.Cache = cache
+			if err != nil {
+				errorLog.Println("Error: renew", host, err)
+				failed = append(failed, host)
+				continue
+			}
+			recordExpiry(host, cert.Leaf)
+			infoLog.Println("renewed", host, "until", cert.Leaf.NotAfter.Format(time.RFC3339))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Couldn't renew %v", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// renewCache hides the certificate being renewed from autocert, so it gets a new one.
+// The new one is put in the cache as usual, if the CA fails the old one stays.
+type renewCache struct {
+	autocert.Cache
+	key string
+}
+
+func (c renewCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if key == c.key {
+		return nil, autocert.ErrCacheMiss
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+// describe fills in the details of the certificate.
+func (info *CertInfo) describe(leaf *x509.Certificate) {
+	info.Cached = true
+	info.Issuer = leaf.Issuer.CommonName
+	if len(info.Issuer) == 0 && len(leaf.Issuer.Organization) > 0 {
+		info.Issuer = leaf.Issuer.Organization[0]
+	}
+	info.Names = leaf.DNSNames
+	info.NotAfter = leaf.NotAfter
+}
+
+// cachedCert reads the certificate autocert keeps for `host`, or returns autocert.ErrCacheMiss.
+func cachedCert(acme config.ConfigACME, host string) (*x509.Certificate, error) {
+	cache := acme.Cache
+	if len(cache) == 0 {
+		cache = defaultACMECache
+	}
+	data, err := autocert.DirCache(cache).Get(context.Background(), cacheKey(acme, host))
+	if err != nil {
+		return nil, err
+	}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+	return nil, fmt.Errorf("No certificate in the acme cache for %v", host)
+}
+
+// cacheKey is the name autocert stores the certificate of `host` under, RSA ones have their own.
+func cacheKey(acme config.ConfigACME, host string) string {
+	host = strings.ToLower(host)
+	if acme.KeyType == config.KeyTypeRSA {
+		return host + "+rsa"
+	}
+	return host
+}
+
+// renewHello is a handshake from a client that supports ECDSA, the kind autocert gets by default.
+func renewHello(host string) *tls.ClientHelloInfo {
+	return &tls.ClientHelloInfo{
+		ServerName:   host,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+}
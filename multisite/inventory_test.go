@@ -0,0 +1,112 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Certificates(t *testing.T) {
+	// GIVEN a site with certificate files, and a letsencrypt site with a certificate in the acme cache
+	dir, err := ioutil.TempDir("", "webd-inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir, "files", "files.example.com")
+	cacheDir := filepath.Join(dir, "cache")
+	if err = os.Mkdir(cacheDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	acmeCert, acmeKey := writeTestCert(t, dir, "acme", "acme.example.com", "www.acme.example.com")
+	certPEM, _ := ioutil.ReadFile(acmeCert)
+	keyPEM, _ := ioutil.ReadFile(acmeKey)
+	if err = ioutil.WriteFile(filepath.Join(cacheDir, "acme.example.com"), append(keyPEM, certPEM...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "sites.yaml")
+	yaml := "- host: files.example.com\n  cert: " + certFile + "\n  key: " + keyFile + "\n" +
+		"- host: acme.example.com\n  aliases: [www.acme.example.com]\n  letsencrypt: true\n  acme:\n    cache: " + cacheDir + "\n" +
+		"- host: plain.example.com\n"
+	if err = ioutil.WriteFile(configFile, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN listing the certificates
+	infos, err := Certificates(configFile)
+
+	// THEN every host is listed with where its certificate comes from
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 4 {
+		t.Fatalf("expecting 4 hosts, got %v", len(infos))
+	}
+	files := infos[0]
+	if files.Host != "files.example.com" || files.Source != CertFromFile || !files.Cached || files.Allowed {
+		t.Errorf("files.example.com %+v", files)
+	}
+	acme := infos[1]
+	if acme.Host != "acme.example.com" || acme.Source != CertFromACME || !acme.Cached || !acme.Allowed {
+		t.Errorf("acme.example.com %+v", acme)
+	}
+	if acme.Issuer != "acme.example.com" || len(acme.Names) != 2 || acme.NotAfter.IsZero() {
+		t.Errorf("acme.example.com certificate %+v", acme)
+	}
+	www := infos[2]
+	if www.Host != "www.acme.example.com" || www.Site != "acme.example.com" || www.Cached || www.Err != nil {
+		t.Errorf("the alias isn't cached yet %+v", www)
+	}
+	plain := infos[3]
+	if plain.Source != "" || plain.Cached || plain.Allowed {
+		t.Errorf("plain.example.com doesn't use TLS %+v", plain)
+	}
+}
+
+func Test_RenewCertificates_failed(t *testing.T) {
+	// GIVEN a letsencrypt site with a cached certificate expiring soon, and a CA that can't be reached
+	dir, err := ioutil.TempDir("", "webd-renew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	if err = os.Mkdir(cacheDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	acmeCert, acmeKey := writeTestCert(t, dir, "acme", "acme.example.com")
+	certPEM, _ := ioutil.ReadFile(acmeCert)
+	keyPEM, _ := ioutil.ReadFile(acmeKey)
+	cached := filepath.Join(cacheDir, "acme.example.com")
+	if err = ioutil.WriteFile(cached, append(keyPEM, certPEM...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "sites.yaml")
+	yaml := "- host: acme.example.com\n  letsencrypt: true\n  acme:\n    directory: http://127.0.0.1:1/directory\n    cache: " + cacheDir + "\n"
+	if err = ioutil.WriteFile(configFile, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+
+	// WHEN it isn't within the renewal window THEN it's kept without asking the CA
+	if err = RenewCertificates(configFile, time.Hour, testLog, testLog); err != nil {
+		t.Errorf("Expecting no renewal got %v", err)
+	}
+
+	// WHEN it's renewed
+	err = RenewCertificates(configFile, 48*time.Hour, testLog, testLog)
+
+	// THEN the renewal fails, and the old certificate is still cached
+	if err == nil {
+		t.Error("Expecting an error from the CA")
+	}
+	if data, _ := ioutil.ReadFile(cached); !bytes.Contains(data, certPEM) {
+		t.Error("Expecting the old certificate to stay in the cache")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenersEnv lists the binds of the sockets an Upgrade passes to the new process,
+// in the order of their file descriptors starting after stderr.
+const listenersEnv = "WEBD_LISTENERS"
+
+// readyEnv is the file descriptor of the pipe the process that started this one waits on,
+// it's written to once this one is serving.
+const readyEnv = "WEBD_READY"
+
+// inherited are the sockets passed in by the process that started this one, by bind.
+var inherited = struct {
+	sync.Mutex
+	once      sync.Once
+	listeners map[string]net.Listener
+}{listeners: make(map[string]net.Listener)}
+
+// inheritListeners picks up the sockets listed in listenersEnv, once.
+func inheritListeners() {
+	inherited.once.Do(func() {
+		binds := os.Getenv(listenersEnv)
+		os.Unsetenv(listenersEnv)
+		if len(binds) == 0 {
+			return
+		}
+		for i, bind := range strings.Split(binds, ",") {
+			f := os.NewFile(uintptr(3+i), bind)
+			l, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				continue
+			}
+			inherited.listeners[bind] = l
+		}
+	})
+}
+
+// listen uses the socket inherited for `bind` if there is one, otherwise it opens a new one.
+func listen(bind string) (net.Listener, error) {
+	inheritListeners()
+	inherited.Lock()
+	defer inherited.Unlock()
+	if l, ok := inherited.listeners[bind]; ok {
+		delete(inherited.listeners, bind)
+		return l, nil
+	}
+	if len(bind) == 0 {
+		bind = ":http"
+	}
+	return net.Listen("tcp", bind)
+}
+
+// signalReady tells the process that started this one with an Upgrade that this one is serving, so it can stop.
+func signalReady() error {
+	fd := os.Getenv(readyEnv)
+	os.Unsetenv(readyEnv)
+	if len(fd) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return fmt.Errorf("Bad %v %q", readyEnv, fd)
+	}
+	f := os.NewFile(uintptr(n), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
+
+// closeInherited closes the inherited sockets that no server took, like a bind removed from the config.
+func closeInherited() {
+	inherited.Lock()
+	defer inherited.Unlock()
+	for bind, l := range inherited.listeners {
+		l.Close()
+		delete(inherited.listeners, bind)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"net"
+	"testing"
+)
+
+func Test_listen_inherited(t *testing.T) {
+	// GIVEN a socket inherited for a bind
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	bind := l.Addr().String()
+	inheritListeners()
+	inherited.Lock()
+	inherited.listeners[bind] = l
+	inherited.Unlock()
+
+	// WHEN the bind is listened to
+	got, err := listen(bind)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the inherited socket is used, only once
+	if got != l {
+		t.Errorf("Expecting the inherited listener %v got %v", l, got)
+	}
+	inherited.Lock()
+	_, left := inherited.listeners[bind]
+	inherited.Unlock()
+	if left {
+		t.Error("Expecting the inherited listener to be taken")
+	}
+}
+
+func Test_closeInherited(t *testing.T) {
+	// GIVEN a socket inherited for a bind no server uses
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bind := l.Addr().String()
+	inheritListeners()
+	inherited.Lock()
+	inherited.listeners[bind] = l
+	inherited.Unlock()
+
+	// WHEN the unused sockets are closed
+	closeInherited()
+
+	// THEN the bind is free again
+	l, err = net.Listen("tcp", bind)
+	if err != nil {
+		t.Fatalf("Expecting %v to be closed: %v", bind, err)
+	}
+	l.Close()
+}
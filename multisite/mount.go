@@ -0,0 +1,126 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"github.com/robert-wallis/webd/compress"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/metrics"
+	"github.com/robert-wallis/webd/proxy"
+	"github.com/robert-wallis/webd/site"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// mount serves a path prefix of a site's host, the site itself is the mount at /.
+type mount struct {
+	prefix  string // always ends in /
+	strip   bool
+	name    string // the handler label of its requests, unless it sets one
+	handler http.Handler
+	closer  io.Closer // stops what the mount runs in the background, or nil
+}
+
+// newMount makes the handler of `cfg`, templates get `base` with the prefix as their base url.
+func newMount(cfg config.ConfigMount, base *url.URL, infoLog, errorLog *log.Logger) (m *mount, err error) {
+	m = &mount{
+		prefix: config.CleanPrefix(cfg.Prefix),
+		strip:  cfg.Strip,
+		name:   metrics.Page,
+	}
+	switch {
+	case cfg.Static:
+		m.handler = site.CacheControl(compress.FileServer(cfg.Path), cfg.CacheControl)
+		m.name = metrics.Static
+	case len(cfg.Proxy.Upstreams) > 0:
+		p, err := proxy.New(cfg.Proxy, infoLog, errorLog)
+		if err != nil {
+			return nil, err
+		}
+		m.handler, m.closer, m.name = p, p, metrics.Proxy
+	default:
+		siteBase := *base
+		if m.prefix != "/" {
+			siteBase.Path = m.prefix
+		}
+		s, err := site.New(&siteBase, cfg.Path, cfg.LiveRefresh, infoLog, errorLog)
+		if err != nil {
+			return nil, err
+		}
+		s.SetCacheControl(cfg.CacheControl)
+		m.handler, m.closer = s, s
+	}
+	return
+}
+
+// mounts sends each request to the mount with the longest prefix of its path.
+type mounts []*mount
+
+// newMounts makes the mounts of a site, with the site itself at /.
+// If a mount fails the ones already made are closed.
+func newMounts(cfg *config.Config, base *url.URL, infoLog, errorLog *log.Logger) (ms mounts, err error) {
+	for _, mc := range append([]config.ConfigMount{cfg.Root()}, cfg.Mounts...) {
+		m, err := newMount(mc, base, infoLog, errorLog)
+		if err != nil {
+			ms.Close()
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	sort.SliceStable(ms, func(i, j int) bool {
+		return len(ms[i].prefix) > len(ms[j].prefix)
+	})
+	return
+}
+
+func (ms mounts) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for _, m := range ms {
+		if req.URL.Path+"/" == m.prefix {
+			// like http.ServeMux, the folder is the prefix with the /
+			u := *req.URL
+			u.Path = m.prefix
+			u.RawPath = ""
+			metrics.SetHandler(req, metrics.Redirect)
+			http.Redirect(w, req, u.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(req.URL.Path, m.prefix) {
+			continue
+		}
+		metrics.SetHandler(req, m.name)
+		if m.strip && m.prefix != "/" {
+			req = stripPrefix(req, m.prefix)
+		}
+		m.handler.ServeHTTP(w, req)
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// stripPrefix is a copy of `req` without `prefix` at the start of its path, keeping the first /.
+func stripPrefix(req *http.Request, prefix string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.URL = new(url.URL)
+	*r.URL = *req.URL
+	r.URL.Path = "/" + strings.TrimPrefix(req.URL.Path, prefix)
+	r.URL.RawPath = ""
+	return r
+}
+
+// Close stops every mount, the error is from the last one that failed.
+func (ms mounts) Close() (err error) {
+	for _, m := range ms {
+		if m.closer == nil {
+			continue
+		}
+		if closeErr := m.closer.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/site"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_mounts(t *testing.T) {
+	// GIVEN a host with templates at /, static files, an app, and the templates again under /docs/
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "app %v", req.URL.Path)
+	}))
+	defer app.Close()
+	cfg := &config.Config{
+		Host: "example.com",
+		Path: "../example",
+		Mounts: []config.ConfigMount{
+			{Prefix: "/downloads", Static: true, Strip: true, Path: "../test_data/files.example.com"},
+			{Prefix: "/api/", Strip: true, Proxy: config.ConfigProxy{Upstreams: []string{app.URL}}},
+			{Prefix: "/api/v0/", Proxy: config.ConfigProxy{Upstreams: []string{app.URL}}},
+			{Prefix: "/docs/", Path: "../example"},
+		},
+	}
+	base, _ := url.Parse("https://example.com")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := newMounts(cfg, base, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Close()
+
+	type test struct {
+		path     string
+		code     int
+		contains string
+	}
+	tests := []test{
+		{"/", 200, `<link rel="canonical" href="https://example.com">`},
+		{"/privacy/", 200, "Privacy Policy"},
+		{"/downloads/files.example.com.txt", 200, ""},
+		{"/downloads", 301, ""},
+		{"/api/users?id=1", 200, "app /users"},
+		{"/api/v0/users", 200, "app /api/v0/users"},
+		{"/docs/", 200, `<link rel="canonical" href="https://example.com/docs/">`},
+		{"/docs/privacy/", 200, "Privacy Policy"},
+		{"/docs/blog", 301, ""},
+		{"/docs/privacy.html", 301, ""},
+		{"/docs/blog/feed.xml", 200, "<link>https://example.com/docs/blog/trip/</link>"},
+		{"/docs/sitemap.xml", 200, "<loc>https://example.com/docs/privacy/</loc>"},
+		{"/docs/nope", 404, "Not Found"},
+	}
+	for _, tt := range tests {
+		// WHEN each is asked for
+		w := httptest.NewRecorder()
+		ms.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com"+tt.path, nil))
+
+		// THEN the longest prefix answers
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.contains) {
+			t.Errorf("%v expecting %v %q got %v %q", tt.path, tt.code, tt.contains, w.Code, w.Body.String())
+		}
+	}
+
+	// THEN the fingerprinted static files are under the prefix too
+	w := httptest.NewRecorder()
+	ms.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/docs/", nil))
+	asset := regexp.MustCompile(`/docs/css/index\.[0-9a-f]+\.css`).FindString(w.Body.String())
+	w = httptest.NewRecorder()
+	ms.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com"+asset, nil))
+	if len(asset) == 0 || w.Code != 200 || w.Header().Get("Cache-Control") != site.ImmutableCacheControl {
+		t.Errorf("Expecting the fingerprinted css under /docs/ got %q %v", asset, w.Code)
+	}
+
+	// THEN the redirects stay under the prefix
+	redirects := map[string]string{
+		"/downloads":         "/downloads/",
+		"/docs/blog":         "/docs/blog/",
+		"/docs/privacy.html": "/docs/privacy/",
+	}
+	for from, to := range redirects {
+		w := httptest.NewRecorder()
+		ms.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com"+from, nil))
+		if got := w.Header().Get("Location"); got != to {
+			t.Errorf("%v expecting a redirect to %v got %v", from, to, got)
+		}
+	}
+}
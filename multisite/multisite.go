@@ -6,71 +6,153 @@ package multisite
 import (
 	"context"
 	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/watch"
 	"log"
+	"net"
+	"net/http"
+	"os"
 	"sync"
 )
 
 // MultiSite manages multiple different sites.
 type MultiSite struct {
-	infoLog  *log.Logger
-	errorLog *log.Logger
-	sites    []*serverSite
+	configFilename string
+	acme           *acmeManagers // shared by every bind, nil without auto-cert
+	infoLog        *log.Logger
+	errorLog       *log.Logger
+	mu             sync.Mutex // guards sites, serving, err, the admin server and watcher
+	reloading      sync.Mutex // held by Reload, so one runs at a time
+	sites          []*serverSite
+	adminBind      string
+	admin          *http.Server
+	adminListener  net.Listener
+	serving        bool
+	err            error
+	running        sync.WaitGroup
+	watcher        *watch.Watcher
+	hangup         chan os.Signal
 }
 
 // New loads a sites.yaml file and creates servers for unique binds internally.
+// The file is checked first, any Problems with it are returned together.
 func New(configFilename string, autoCert bool, infoLog, errorLog *log.Logger) (*MultiSite, error) {
-	sites, err := config.Load(configFilename)
+	if err := Check(configFilename); err != nil {
+		return nil, err
+	}
+	settings, err := config.LoadSettings(configFilename)
 	if err != nil {
 		return nil, err
 	}
 
-	httpSites := config.GroupServers(sites)
+	httpSites := config.GroupServers(settings.Sites)
 	m := &MultiSite{
-		infoLog:  infoLog,
-		errorLog: errorLog,
-		sites:    []*serverSite{},
+		configFilename: configFilename,
+		infoLog:        infoLog,
+		errorLog:       errorLog,
+		sites:          []*serverSite{},
+		adminBind:      settings.Admin.Bind,
+	}
+	if autoCert {
+		m.acme = newACMEManagers(m.hostPolicy)
 	}
 	for bind, list := range httpSites {
-		s, err := newServerSite(bind, list, autoCert, infoLog, errorLog)
+		s, err := newServerSite(bind, list, m.acme, infoLog, errorLog)
 		if err != nil {
 			return nil, err
 		}
+		if err = s.logUnknownHosts(settings.AccessLog); err != nil {
+			return nil, err
+		}
 		m.sites = append(m.sites, s)
 	}
 	return m, nil
 }
 
 // ListenAndServe starts each server in MultiSite, blocks until all inner ListenAndServe return.
+// Servers added by a Reload while serving are waited on as well.
+// Returns nil once Shutdown stops the servers, Shutdown itself returns when they finish draining.
 func (m *MultiSite) ListenAndServe() error {
-	wg := sync.WaitGroup{}
-	wg.Add(len(m.sites))
-	var err error
+	m.mu.Lock()
+	m.serving = true
 	for s := range m.sites {
-		site := m.sites[s]
-		for r := range site.runningSites {
-			m.infoLog.Println("starting", site.runningSites[r].config.Host, "on", site.runningSites[r].bind)
+		m.start(m.sites[s])
+	}
+	m.startAdmin()
+	closeInherited()
+	if m.err == nil {
+		if err := signalReady(); err != nil {
+			m.errorLog.Println("Error: telling the old process this one is serving", err)
 		}
-		go func(site *serverSite) {
-			err = site.ListenAndServe()
-			wg.Done()
-		}(m.sites[s])
 	}
-	wg.Wait()
-	return err
+	m.mu.Unlock()
+	m.running.Wait()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// start runs the server in the background, m.mu must be held.
+func (m *MultiSite) start(site *serverSite) {
+	for r := range site.runningSites {
+		m.infoLog.Println("starting", site.runningSites[r].config.Host, "on", site.runningSites[r].bind)
+	}
+	l, err := listen(site.bind)
+	if err != nil {
+		m.errorLog.Println("Error: listen", site.bind, err)
+		m.err = err
+		return
+	}
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		err := site.Serve(l)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if site.removed {
+			m.infoLog.Println("stopped", site.bind)
+			return
+		}
+		if err != http.ErrServerClosed {
+			m.err = err
+		}
+	}()
 }
 
-// Shutdown gracefully shuts down all the servers.
-func (m *MultiSite) Shutdown(ctx context.Context) {
+// Shutdown gracefully shuts down all the servers, letting requests in flight finish until `ctx` is done.
+// The result for each host is logged, the first error is returned, like context.DeadlineExceeded.
+func (m *MultiSite) Shutdown(ctx context.Context) (err error) {
+	m.stopWatching()
+	m.mu.Lock()
+	sites := m.sites
+	m.stopAdmin(ctx)
+	m.mu.Unlock()
 	wg := sync.WaitGroup{}
-	for s := range m.sites {
-		site := m.sites[s]
+	errs := make([]error, len(sites))
+	for s := range sites {
+		s := s
+		site := sites[s]
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := site.Shutdown(ctx); err != nil {
-				m.errorLog.Println("Error: shutdown", site.runningSites[0].config.Host, err)
+			errs[s] = site.Shutdown(ctx)
+			site.mu.RLock()
+			for r := range site.runningSites {
+				host := site.runningSites[r].config.Host
+				if errs[s] != nil {
+					m.errorLog.Println("Error: shutdown", host, "on", site.bind, errs[s])
+				} else {
+					m.infoLog.Println("stopped", host, "on", site.bind)
+				}
 			}
+			site.mu.RUnlock()
+			site.close()
 		}()
 	}
 	wg.Wait()
+	for e := range errs {
+		if errs[e] != nil {
+			return errs[e]
+		}
+	}
+	return nil
 }
@@ -105,7 +105,7 @@ func Test_MultiSite_https_redirect(t *testing.T) {
 		t.Errorf("Expecting 301 got %v", rr.Code)
 	}
 	location := rr.HeaderMap.Get("Location")
-	if location != "https://example.com/" {
+	if location != "https://example.com:8443/" {
 		t.Errorf("Expecting https, got %v", location)
 	}
 }
@@ -205,7 +205,7 @@ func Test_MultiSite_Shutdown_error(t *testing.T) {
 		t.Fatal(err)
 	}
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
-	ms.Shutdown(ctx)
+	shutdownErr := ms.Shutdown(ctx)
 	hangingClient.Close()
 	cancel()
 
@@ -214,6 +214,9 @@ func Test_MultiSite_Shutdown_error(t *testing.T) {
 	if !strings.Contains(errStr, "deadline exceeded") {
 		t.Errorf(`Expected error "deadline exceeded" not found in error log: "%v"`, errStr)
 	}
+	if shutdownErr != context.DeadlineExceeded {
+		t.Errorf("Expecting Shutdown to return %v got %v", context.DeadlineExceeded, shutdownErr)
+	}
 }
 
 func injectTestServers(ms *MultiSite) (prodUrl, testUrl, secureUrl string) {
@@ -0,0 +1,186 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"context"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/watch"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloadDelay waits for an editor to finish saving the config file before reloading it.
+const reloadDelay = 500 * time.Millisecond
+
+// removedShutdownTimeout is how long a server whose bind was removed gets to finish its requests.
+const removedShutdownTimeout = 30 * time.Second
+
+// Reload loads the config file again, and starts, stops or updates servers so they match it.
+// Hosts whose config didn't change keep serving without interruption.
+// If the new config has an error, or any of the Problems Check finds, the old one keeps running.
+// The new sites are built while the old ones keep serving, m.mu is only held to swap them in,
+// so certificate lookups that need it aren't held up by a slow reload.
+func (m *MultiSite) Reload() error {
+	m.reloading.Lock()
+	defer m.reloading.Unlock()
+	if err := Check(m.configFilename); err != nil {
+		return err
+	}
+	settings, err := config.LoadSettings(m.configFilename)
+	if err != nil {
+		return err
+	}
+	binds := config.GroupServers(settings.Sites)
+
+	// only Reload changes the list, and one runs at a time
+	m.mu.Lock()
+	sites := m.sites
+	m.mu.Unlock()
+
+	// build everything first, so an error doesn't leave a half reloaded config
+	updates := make(map[*serverSite]*siteUpdate)
+	var kept, removed, added []*serverSite
+	for s := range sites {
+		site := sites[s]
+		configs, ok := binds[site.bind]
+		if !ok {
+			removed = append(removed, site)
+			continue
+		}
+		u, err := site.prepare(configs)
+		if err != nil {
+			discardReload(updates, added)
+			return err
+		}
+		updates[site] = u
+		kept = append(kept, site)
+	}
+	for bind, configs := range binds {
+		if findServerSite(kept, bind) != nil {
+			continue
+		}
+		site, err := newServerSite(bind, configs, m.acme, m.infoLog, m.errorLog)
+		if err != nil {
+			discardReload(updates, added)
+			return err
+		}
+		added = append(added, site)
+	}
+	for _, site := range append(kept, added...) {
+		if err := site.logUnknownHosts(settings.AccessLog); err != nil {
+			m.errorLog.Println("Error: access log", site.bind, err)
+		}
+	}
+
+	// now swap it all in
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for site, u := range updates {
+		site.apply(u)
+	}
+	for s := range removed {
+		site := removed[s]
+		site.removed = true
+		m.infoLog.Println("stopping", site.bind)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), removedShutdownTimeout)
+			defer cancel()
+			if err := site.Shutdown(ctx); err != nil {
+				m.errorLog.Println("Error: shutdown", site.bind, err)
+			}
+			site.close()
+		}()
+	}
+	m.sites = append(kept, added...)
+	if m.serving {
+		for s := range added {
+			m.start(added[s])
+		}
+	}
+	m.rebindAdmin(settings.Admin.Bind)
+	return nil
+}
+
+// Watch reloads the config whenever the config file or the folders it includes change, or the process gets a SIGHUP.
+// The folders are found again after each reload, so ones a changed include adds are watched too.
+func (m *MultiSite) Watch() (err error) {
+	watcher, err := watch.New(m.watchPaths(), reloadDelay, m.errorLog, m.reload)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	m.watcher = watcher
+	m.mu.Unlock()
+	m.hangup = make(chan os.Signal, 1)
+	signal.Notify(m.hangup, syscall.SIGHUP)
+	go func(hangup chan os.Signal) {
+		for range hangup {
+			m.reload()
+		}
+	}(m.hangup)
+	return
+}
+
+// watchPaths are the config file and the folders it includes.
+func (m *MultiSite) watchPaths() []string {
+	paths := []string{m.configFilename}
+	if settings, err := config.LoadSettings(m.configFilename); err == nil {
+		paths = append(paths, settings.Include.Folders()...)
+	}
+	return paths
+}
+
+// stopWatching undoes Watch.
+func (m *MultiSite) stopWatching() {
+	m.mu.Lock()
+	watcher := m.watcher
+	m.watcher = nil
+	m.mu.Unlock()
+	if watcher != nil {
+		watcher.Close()
+	}
+	if m.hangup != nil {
+		signal.Stop(m.hangup)
+		close(m.hangup)
+		m.hangup = nil
+	}
+}
+
+// reload logs the result of a Reload.
+func (m *MultiSite) reload() {
+	m.infoLog.Println("reloading", m.configFilename)
+	if err := m.Reload(); err != nil {
+		m.errorLog.Println("Error: reload", m.configFilename, err)
+	}
+	m.mu.Lock()
+	watcher := m.watcher
+	m.mu.Unlock()
+	if watcher != nil {
+		if err := watcher.Add(m.watchPaths()...); err != nil {
+			m.errorLog.Println("Error: watch", m.configFilename, err)
+		}
+	}
+}
+
+// discardReload throws away everything a failed Reload built.
+func discardReload(updates map[*serverSite]*siteUpdate, added []*serverSite) {
+	for _, u := range updates {
+		u.discard()
+	}
+	for s := range added {
+		added[s].close()
+	}
+}
+
+// findServerSite returns the server with the matching bind, or nil.
+func findServerSite(sites []*serverSite, bind string) *serverSite {
+	for s := range sites {
+		if sites[s].bind == bind {
+			return sites[s]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestConfig saves a sites.yaml with static sites that point back at the test_data folder.
+func writeTestConfig(t *testing.T, filename string, sites ...string) {
+	testData, err := filepath.Abs("../test_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	for s := 0; s < len(sites); s += 2 {
+		host := sites[s]
+		fmt.Fprintf(buf, "-\n  host: %v\n  static: true\n  path: %v\n  bind:\n    http: %v\n", host, filepath.Join(testData, host), sites[s+1])
+	}
+	if err = ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_MultiSite_Reload(t *testing.T) {
+	// GIVEN a running config
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFilename := filepath.Join(dir, "sites.yaml")
+	writeTestConfig(t, configFilename,
+		"files.example.com", "localhost:8101",
+		"test.example.com", "localhost:8202",
+	)
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := New(configFilename, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldProd := findServerSite(ms.sites, "localhost:8101")
+	oldTest := findServerSite(ms.sites, "localhost:8202")
+	unchanged := oldProd.hostMap["files.example.com"]
+
+	// WHEN the config changes and is reloaded
+	writeTestConfig(t, configFilename,
+		"files.example.com", "localhost:8101",
+		"secure.example.com", "localhost:8101",
+		"test.example.com", "localhost:8303",
+	)
+	if err = ms.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the unchanged site should keep running as is
+	prod := findServerSite(ms.sites, "localhost:8101")
+	if prod != oldProd {
+		t.Error("Expecting the server on an unchanged bind to be kept")
+	}
+	if prod.hostMap["files.example.com"] != unchanged {
+		t.Error("Expecting the unchanged site to be kept")
+	}
+
+	// THEN the added host should be mapped
+	if _, ok := prod.hostMap["secure.example.com"]; !ok {
+		t.Error("Expecting secure.example.com to be added to localhost:8101")
+	}
+
+	// THEN the removed bind should be stopped and the new one should be added
+	if findServerSite(ms.sites, "localhost:8202") != nil || !oldTest.removed {
+		t.Error("Expecting localhost:8202 to be removed")
+	}
+	if s := findServerSite(ms.sites, "localhost:8303"); s == nil || s.hostMap["test.example.com"] == nil {
+		t.Error("Expecting test.example.com on localhost:8303")
+	}
+	if len(ms.sites) != 2 {
+		t.Errorf("Expecting 2 servers got %v", len(ms.sites))
+	}
+}
+
+func Test_MultiSite_Reload_error(t *testing.T) {
+	// GIVEN a running config
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFilename := filepath.Join(dir, "sites.yaml")
+	writeTestConfig(t, configFilename, "files.example.com", "localhost:8101")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := New(configFilename, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN the config is broken and reloaded
+	ioutil.WriteFile(configFilename, []byte("not: [yaml"), 0644)
+	err = ms.Reload()
+
+	// THEN it should error and keep the old config
+	if err == nil {
+		t.Error("Expecting a parse error")
+	}
+	if len(ms.sites) != 1 || ms.sites[0].hostMap["files.example.com"] == nil {
+		t.Error("Expecting the old config to keep running")
+	}
+}
+
+func Test_MultiSite_Reload_unlocked(t *testing.T) {
+	// GIVEN a config whose site is slow to rebuild
+	dir := t.TempDir()
+	configFilename := filepath.Join(dir, "sites.yaml")
+	writeTestConfig(t, configFilename, "files.example.com", "localhost:8101")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := New(configFilename, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := findServerSite(ms.sites, "localhost:8101")
+	writeTestConfig(t, configFilename,
+		"files.example.com", "localhost:8101",
+		"test.example.com", "localhost:8101",
+	)
+	site.mu.Lock()
+
+	// WHEN it's reloaded
+	done := make(chan error)
+	go func() {
+		done <- ms.Reload()
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// THEN the multi-site isn't locked while the site is rebuilt
+	if !ms.mu.TryLock() {
+		t.Error("Expecting the lock to be free while the sites are rebuilt")
+	} else {
+		ms.mu.Unlock()
+	}
+	site.mu.Unlock()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := site.hostMap["test.example.com"]; !ok {
+		t.Error("Expecting the new host after the reload")
+	}
+}
@@ -3,8 +3,12 @@
 package multisite
 
 import (
+	"github.com/robert-wallis/webd/accesslog"
+	"github.com/robert-wallis/webd/compress"
 	"github.com/robert-wallis/webd/config"
-	"github.com/robert-wallis/webd/site"
+	"github.com/robert-wallis/webd/headers"
+	"github.com/robert-wallis/webd/metrics"
+	"golang.org/x/crypto/acme/autocert"
 	"log"
 	"net/http"
 	"net/url"
@@ -15,7 +19,9 @@ type runningSite struct {
 	config     *config.Config
 	serverSite *serverSite
 	handler    http.Handler
-	site       *site.Site
+	mounts     mounts // the site at /, and its mounts
+	accessLog  *accesslog.File
+	acManager  *autocert.Manager // gets the certificates of a `letsencrypt` site, or nil
 	bind       string
 }
 
@@ -25,19 +31,51 @@ func newRunningSite(serverSite *serverSite, config *config.Config, bind string,
 		serverSite: serverSite,
 		bind:       bind,
 	}
-	switch {
-	case config.Static:
-		r.handler = http.FileServer(http.Dir(config.Path))
-	default:
-		base, err := baseUrl(config, bind)
-		if err != nil {
+	if config.LetsEncrypt && serverSite != nil && serverSite.acme != nil {
+		if r.acManager, err = serverSite.acme.manager(config.ACME); err != nil {
 			return nil, err
 		}
-		if r.site, err = site.New(base, config.Path, false, shouldRedirectHttps(config), infoLog, errorLog); err != nil {
-			return nil, err
-		}
-		r.handler = r.site
 	}
+	base, err := baseUrl(config, bind)
+	if err != nil {
+		return nil, err
+	}
+	if r.mounts, err = newMounts(config, base, infoLog, errorLog); err != nil {
+		return nil, err
+	}
+	handlerName := r.mounts[len(r.mounts)-1].name
+	r.handler = r.mounts
+	if len(r.mounts) == 1 {
+		r.handler = r.mounts[0].handler
+	}
+	compressed, err := compress.New(r.handler, config.Compress)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	secured, err := headers.New(r.httpsRedirect(compressed), config.Headers)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	r.handler = metrics.Instrument(secured, config.Host, handlerName)
+	if err = r.logAccess(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return
+}
+
+// logAccess wraps the handler to write each request to the configured access log.
+func (r *runningSite) logAccess() (err error) {
+	cfg := r.config.AccessLog
+	if len(cfg.Path) == 0 {
+		return
+	}
+	if r.accessLog, err = accesslog.Open(cfg.Path, int64(cfg.MaxSize)*1024*1024, cfg.MaxBackups); err != nil {
+		return
+	}
+	r.handler, err = accesslog.New(r.handler, r.accessLog, cfg.Format)
 	return
 }
 
@@ -45,6 +83,19 @@ func (r *runningSite) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.handler.ServeHTTP(w, req)
 }
 
+// Close stops anything the site runs in the background.
+func (r *runningSite) Close() (err error) {
+	if r.accessLog != nil {
+		err = r.accessLog.Close()
+		r.accessLog = nil
+	}
+	if mountErr := r.mounts.Close(); mountErr != nil {
+		err = mountErr
+	}
+	r.mounts = nil
+	return
+}
+
 func (r *runningSite) HostList() (hosts []string) {
 	hl := r.config.HostList()
 	for h := range hl {
@@ -3,7 +3,14 @@
 package multisite
 
 import (
+	"bytes"
 	"github.com/robert-wallis/webd/config"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -95,3 +102,38 @@ func Test_ShouldRedirectHttps(t *testing.T) {
 		}
 	}
 }
+
+func Test_newRunningSite_accessLog(t *testing.T) {
+	// GIVEN a site with an access log
+	dir, err := ioutil.TempDir("", "runningsite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "access.log")
+	cfg := &config.Config{
+		Host:      "files.example.com",
+		Static:    true,
+		Path:      "../test_data/files.example.com",
+		AccessLog: config.ConfigAccessLog{Path: logFile, Format: "common"},
+	}
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	r, err := newRunningSite(nil, cfg, ":80", testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN a request is served
+	req := httptest.NewRequest("GET", "http://files.example.com/files.example.com.txt", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.Close()
+
+	// THEN it should be in the log
+	data, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"GET /files.example.com.txt HTTP/1.1" 200`) {
+		t.Errorf("Expecting the request in the access log, got %v", string(data))
+	}
+}
@@ -5,61 +5,210 @@ package multisite
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/robert-wallis/webd/accesslog"
 	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/metrics"
 	"golang.org/x/crypto/acme"
-	"golang.org/x/crypto/acme/autocert"
 	"log"
+	"net"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
 )
 
 type serverSite struct {
+	bind         string
+	infoLog      *log.Logger
 	errorLog     *log.Logger
 	server       server
+	mu           sync.RWMutex // guards runningSites, hostMap, unknownHost, accessLog, certs, tlsConfig and listener
 	runningSites []*runningSite
 	hostMap      map[string]*runningSite
+	unknownHost  http.Handler    // answers the requests for hosts no site serves
+	accessLog    *accesslog.File // the server wide log of unknownHost, or nil
+	certs        *certStore
+	tlsConfig    *tls.Config
+	listener     net.Listener
 	tlsEnabled   bool
+	acme         *acmeManagers
+	removed      bool // set once a reload takes the bind away
 }
 
-// server is something that can ListenAndServe and Shutdown.
+// server is something that can Serve a listener and Shutdown.
 type server interface {
-	ListenAndServe() error
-	ListenAndServeTLS(certFile, keyFile string) error
+	Serve(l net.Listener) error
+	ServeTLS(l net.Listener, certFile, keyFile string) error
 	Shutdown(ctx context.Context) error
 }
 
 // newServerSite creates and initializes an http.Server to go with a list of configs.
-func newServerSite(bind string, configs []*config.Config, autoCert bool, infoLog, errorLog *log.Logger) (*serverSite, error) {
+// `managers` get certificates from Let's Encrypt, or is nil to only use certificate files.
+func newServerSite(bind string, configs []*config.Config, managers *acmeManagers, infoLog, errorLog *log.Logger) (*serverSite, error) {
 	s := &serverSite{
+		bind:         bind,
+		infoLog:      infoLog,
 		errorLog:     errorLog,
 		runningSites: []*runningSite{},
 		hostMap:      make(map[string]*runningSite),
+		acme:         managers,
 	}
+	s.unknownHost = http.HandlerFunc(s.notConfigured)
 	hs := &http.Server{
 		Addr:     bind,
 		Handler:  s,
 		ErrorLog: errorLog,
 	}
 	s.server = hs
+	var err error
+	if s.tlsEnabled, err = isTLSBind(bind, configs); err != nil {
+		return nil, err
+	}
+	u, err := s.prepare(configs)
+	if err != nil {
+		return nil, err
+	}
+	s.apply(u)
+	if s.tlsEnabled {
+		s.initTLS(hs)
+	}
+	return s, nil
+}
+
+// siteUpdate is the set of sites a serverSite will switch to, built before anything is swapped.
+type siteUpdate struct {
+	runningSites []*runningSite
+	hostMap      map[string]*runningSite
+	certs        *certStore
+	tlsConfig    *tls.Config
+	created      []*runningSite // new sites that aren't running yet
+	unused       []*runningSite // previous sites that are no longer needed
+}
+
+// prepare builds the sites for `configs`, reusing the running sites whose config didn't change.
+func (s *serverSite) prepare(configs []*config.Config) (u *siteUpdate, err error) {
+	isTLS, err := isTLSBind(s.bind, configs)
+	if err != nil {
+		return nil, err
+	}
+	if isTLS != s.tlsEnabled {
+		return nil, fmt.Errorf("Switching %v between http and https needs a restart", s.bind)
+	}
+	var tlsConfig *tls.Config
+	if isTLS {
+		opts, err := tlsOptions(s.bind, configs)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig, err = newTLSConfig(s.bind, opts, s.getCertificate); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	previous := s.runningSites
+	s.mu.RUnlock()
+
+	u = &siteUpdate{
+		runningSites: []*runningSite{},
+		hostMap:      make(map[string]*runningSite),
+		tlsConfig:    tlsConfig,
+	}
+	reused := make(map[*runningSite]bool)
 	for c := range configs {
 		cfg := configs[c]
-		r, err := newRunningSite(s, cfg, bind, infoLog, errorLog)
-		if err != nil {
+		r := findRunningSite(previous, cfg)
+		if r != nil && !reused[r] {
+			reused[r] = true
+		} else if r, err = newRunningSite(s, cfg, s.bind, s.infoLog, s.errorLog); err != nil {
+			u.discard()
 			return nil, err
+		} else {
+			u.created = append(u.created, r)
 		}
-		s.appendHostMap(r)
-		s.runningSites = append(s.runningSites, r)
+		appendHostMap(u.hostMap, r)
+		u.runningSites = append(u.runningSites, r)
 	}
-	s.initTLS(bind, configs[0].Host, hs, autoCert)
-	return s, nil
+	if u.certs, err = loadCertStore(u.runningSites); err != nil {
+		u.discard()
+		return nil, err
+	}
+	for p := range previous {
+		if !reused[previous[p]] {
+			u.unused = append(u.unused, previous[p])
+		}
+	}
+	return
 }
 
-// ListenAndServe starts the underlying http server.
-func (s *serverSite) ListenAndServe() error {
+// apply swaps in the prepared sites, requests in flight finish on the site they started on.
+func (s *serverSite) apply(u *siteUpdate) {
+	s.mu.Lock()
+	s.runningSites = u.runningSites
+	s.hostMap = u.hostMap
+	s.certs = u.certs
+	s.tlsConfig = u.tlsConfig
+	s.mu.Unlock()
+	s.recordCertificates(u)
+	for r := range u.unused {
+		s.infoLog.Println("stopping", u.unused[r].config.Host, "on", s.bind)
+		if err := u.unused[r].Close(); err != nil {
+			s.errorLog.Println("Error: close", u.unused[r].config.Host, err)
+		}
+	}
+}
+
+// discard closes the new sites of an update that will never be applied.
+func (u *siteUpdate) discard() {
+	for r := range u.created {
+		u.created[r].Close()
+	}
+}
+
+// close stops the background work of every site, once the server is done with them.
+func (s *serverSite) close() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.accessLog != nil {
+		if err := s.accessLog.Close(); err != nil {
+			s.errorLog.Println("Error: close access log", s.bind, err)
+		}
+	}
+	for r := range s.runningSites {
+		if err := s.runningSites[r].Close(); err != nil {
+			s.errorLog.Println("Error: close", s.runningSites[r].config.Host, err)
+		}
+	}
+}
+
+// findRunningSite returns the site that is running with exactly the same config, or nil.
+func findRunningSite(sites []*runningSite, cfg *config.Config) *runningSite {
+	for r := range sites {
+		if reflect.DeepEqual(sites[r].config, cfg) {
+			return sites[r]
+		}
+	}
+	return nil
+}
+
+// Serve runs the underlying http server on `l`, a new socket or one inherited from the previous process.
+func (s *serverSite) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
 	if s.tlsEnabled {
-		return s.server.ListenAndServeTLS("", "")
+		return s.server.ServeTLS(l, "", "")
 	}
-	return s.server.ListenAndServe()
+	return s.server.Serve(l)
+}
+
+// Listener is the socket being served, or nil before Serve.
+func (s *serverSite) Listener() net.Listener {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listener
 }
 
 // Shutdown gracefully stops the server.
@@ -69,20 +218,52 @@ func (s *serverSite) Shutdown(ctx context.Context) error {
 
 // Handler takes an incoming request and sends it off to the correct site within MultiSite.
 func (s *serverSite) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
 	r, ok := s.hostMap[stripPort(req.Host)]
+	unknownHost := s.unknownHost
+	s.mu.RUnlock()
 	if !ok {
-		s.errorLog.Println(http.StatusBadGateway, req.Host, req.URL, req.Header)
-		http.Error(w, "Site Not Configured", http.StatusBadGateway)
+		unknownHost.ServeHTTP(w, req)
 		return
 	}
 	r.ServeHTTP(w, req)
 }
 
+// notConfigured answers a request for a host no site on the bind serves.
+func (s *serverSite) notConfigured(w http.ResponseWriter, req *http.Request) {
+	s.errorLog.Println(http.StatusBadGateway, req.Host, req.URL, req.Header)
+	http.Error(w, "Site Not Configured", http.StatusBadGateway)
+}
+
+// logUnknownHosts writes the requests for hosts no site serves to the server wide access log `cfg`, replacing the previous one.
+func (s *serverSite) logUnknownHosts(cfg config.ConfigAccessLog) error {
+	var handler http.Handler = http.HandlerFunc(s.notConfigured)
+	var file *accesslog.File
+	if len(cfg.Path) > 0 {
+		var err error
+		if file, err = accesslog.Open(cfg.Path, int64(cfg.MaxSize)*1024*1024, cfg.MaxBackups); err != nil {
+			return err
+		}
+		if handler, err = accesslog.New(handler, file, cfg.Format); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	s.mu.Lock()
+	previous := s.accessLog
+	s.unknownHost, s.accessLog = handler, file
+	s.mu.Unlock()
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
 // appendHostMap adds the host names of the site to a map of sites
-func (s *serverSite) appendHostMap(site *runningSite) {
+func appendHostMap(hostMap map[string]*runningSite, site *runningSite) {
 	hosts := site.config.HostList()
 	for h := range hosts {
-		s.hostMap[hosts[h]] = site
+		hostMap[hosts[h]] = site
 	}
 }
 
@@ -104,31 +285,73 @@ func justPort(host string) string {
 	return host[colon+len(":"):]
 }
 
-// initTLS sets up TLS if the bind port is 443
-func (s *serverSite) initTLS(bind, host string, hs *http.Server, autoCert bool) {
-	if justPort(bind) != "443" {
-		return
-	}
+// initTLS sets up the server to use the TLS config of the current sites, which follows reloads.
+func (s *serverSite) initTLS(hs *http.Server) {
 	hs.TLSConfig = &tls.Config{
-		ServerName: host,
+		GetCertificate:     s.getCertificate,
+		GetConfigForClient: s.getConfigForClient,
 	}
-	if autoCert {
-		s.initAutoCert(hs.TLSConfig)
+}
+
+// getConfigForClient is the TLS config built from the options of the sites on the bind.
+// A Let's Encrypt tls-alpn-01 challenge gets the autocert config instead.
+func (s *serverSite) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if site := s.hostMap[strings.ToLower(hello.ServerName)]; site != nil && site.acManager != nil {
+		for _, proto := range hello.SupportedProtos {
+			if proto == acme.ALPNProto {
+				return site.acManager.TLSConfig(), nil
+			}
+		}
 	}
-	s.tlsEnabled = true
+	return s.tlsConfig, nil
 }
 
-// initAutoCert sets the GetCertificate function to get the cert automatically from the CA (Let's Encrypt)
-func (s *serverSite) initAutoCert(tlsConfig *tls.Config) {
-	hosts := hostList(s.runningSites)
-	acManager := autocert.Manager{
-		Email:      firstEmailFound(s.runningSites),
-		Cache:      autocert.DirCache("autocert"),
-		Prompt:     acme.AcceptTOS,
-		HostPolicy: autocert.HostWhitelist(hosts...),
+// getCertificate picks the certificate by the server name the client asked for.
+// Certificates from files come first, then Let's Encrypt for sites with `letsencrypt: true`.
+func (s *serverSite) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	certs := s.certs
+	site := s.hostMap[strings.ToLower(hello.ServerName)]
+	s.mu.RUnlock()
+	if cert := certs.find(hello.ServerName); cert != nil {
+		recordExpiry(hello.ServerName, cert.Leaf)
+		return cert, nil
+	}
+	if site == nil || site.acManager == nil {
+		return nil, fmt.Errorf("No certificate for %q on %v", hello.ServerName, s.bind)
+	}
+	cert, err := getACMECertificate(site.acManager, site.config.ACME.KeyType, hello)
+	if err == nil {
+		recordExpiry(hello.ServerName, cert.Leaf)
 	}
-	tlsConfig.GetCertificate = func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
-		return acManager.GetCertificate(info)
+	return cert, err
+}
+
+// recordExpiry sets the certificate expiry metric for the host the certificate was served for.
+func recordExpiry(host string, leaf *x509.Certificate) {
+	if leaf == nil || len(host) == 0 {
+		return
+	}
+	metrics.CertificateExpiry.Set(float64(leaf.NotAfter.Unix()), host)
+}
+
+// recordCertificates sets the certificate expiry metric of every host on a TLS bind, from the files or the acme cache,
+// so it's there before the first handshake.
+func (s *serverSite) recordCertificates(u *siteUpdate) {
+	if !s.tlsEnabled {
+		return
+	}
+	for _, r := range u.runningSites {
+		for _, host := range r.HostList() {
+			if cert := u.certs.find(host); cert != nil {
+				recordExpiry(host, cert.Leaf)
+			} else if r.acManager != nil {
+				leaf, _ := cachedCert(r.config.ACME, host)
+				recordExpiry(host, leaf)
+			}
+		}
 	}
 }
 
@@ -142,13 +365,3 @@ func hostList(sites []*runningSite) (hosts []string) {
 	}
 	return
 }
-
-// firstEmailFound checks each site in the list for a configured Email, and returns the first one found.
-func firstEmailFound(sites []*runningSite) (email string) {
-	for r := range sites {
-		if sites[r].config.Email != "" {
-			return sites[r].config.Email
-		}
-	}
-	return ""
-}
@@ -7,9 +7,13 @@ import (
 	"context"
 	"fmt"
 	"github.com/robert-wallis/webd/config"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -24,8 +28,12 @@ func newTestServer(handler http.Handler) *testServer {
 	}
 }
 
-func (s *testServer) ListenAndServe() error {
-	return nil
+func (s *testServer) Serve(l net.Listener) error {
+	return l.Close()
+}
+
+func (s *testServer) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	return l.Close()
 }
 
 func (s *testServer) Shutdown(ctx context.Context) (err error) {
@@ -86,6 +94,33 @@ func Test_MultiSite_serverSite_ServeHTTP(t *testing.T) {
 	}
 }
 
+func Test_serverSite_logUnknownHosts(t *testing.T) {
+	// GIVEN a bind with a server wide access log
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := newServerSite("localhost:8202", []*config.Config{{Host: "test.example.com", Static: true, Path: "../test_data/test.example.com"}}, nil, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(t.TempDir(), "unknown.log")
+	if err = s.logUnknownHosts(config.ConfigAccessLog{Path: logFile, Format: "common"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN a host no site serves is asked for, and one that is
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://nope.example.com/unknown", nil))
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://test.example.com/test.example.com.txt", nil))
+	s.close()
+
+	// THEN only the unknown host is in the log
+	data, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"GET /unknown HTTP/1.1" 502`) || strings.Contains(string(data), "test.example.com.txt") {
+		t.Errorf("Expecting only the unknown host in the access log, got %v", string(data))
+	}
+}
+
 func Test_serverSite_initTLS(t *testing.T) {
 	// GIVEN a partially configured serverSite
 	hs := &http.Server{}
@@ -106,13 +141,9 @@ func Test_serverSite_initTLS(t *testing.T) {
 	}
 
 	// WHEN the serverSite is configured for TLS
-	ss.initTLS(":443", "localhost", hs, true)
+	ss.initTLS(hs)
 
 	// THEN the TLSConfig should be setup
-	if ss.tlsEnabled == false {
-		t.Fatal("Expecting tlsEnabled to be true")
-	}
-
 	if hs.TLSConfig == nil {
 		t.Fatal("TLSConfig was nil")
 	}
@@ -120,6 +151,9 @@ func Test_serverSite_initTLS(t *testing.T) {
 	if hs.TLSConfig.GetCertificate == nil {
 		t.Error("TLSCOnfig.GetCertificate function was not set.")
 	}
+	if hs.TLSConfig.GetConfigForClient == nil {
+		t.Error("Expecting TLSConfig.GetConfigForClient to be set")
+	}
 }
 
 func Test_stripPort(t *testing.T) {
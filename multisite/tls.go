@@ -0,0 +1,88 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"reflect"
+)
+
+// defaultALPN lets clients use HTTP/2, like http.Server does when it has no TLS config of its own.
+var defaultALPN = []string{"h2", "http/1.1"}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// isTLSBind is true if `bind` is the HTTPS bind of the sites, it can't be the HTTP bind of another one.
+func isTLSBind(bind string, configs []*config.Config) (isTLS bool, err error) {
+	var plain string
+	for c := range configs {
+		switch bind {
+		case configs[c].Bind.HTTPS:
+			isTLS = true
+		case configs[c].Bind.HTTP:
+			plain = configs[c].Host
+		}
+	}
+	if isTLS && len(plain) > 0 {
+		return false, fmt.Errorf("%v is an https bind, and an http bind for %v", bind, plain)
+	}
+	return
+}
+
+// tlsOptions are the TLS options the sites set for the HTTPS `bind`.
+// Sites that leave them empty go along with the others, but two different sets are an error.
+func tlsOptions(bind string, configs []*config.Config) (opts config.ConfigTLS, err error) {
+	var from string
+	for c := range configs {
+		cfg := configs[c]
+		if cfg.Bind.HTTPS != bind || reflect.DeepEqual(cfg.Bind.TLS, config.ConfigTLS{}) {
+			continue
+		}
+		if len(from) > 0 && !reflect.DeepEqual(cfg.Bind.TLS, opts) {
+			return opts, fmt.Errorf("TLS options for %v differ between %v and %v", bind, from, cfg.Host)
+		}
+		opts = cfg.Bind.TLS
+		from = cfg.Host
+	}
+	return
+}
+
+// newTLSConfig builds the TLS config of a bind from its options.
+func newTLSConfig(bind string, opts config.ConfigTLS, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate:         getCertificate,
+		NextProtos:             defaultALPN,
+		SessionTicketsDisabled: opts.DisableSessionTickets,
+	}
+	if len(opts.MinVersion) > 0 {
+		version, ok := tlsVersions[opts.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Unknown TLS minversion %q for %v, expecting 1.0, 1.1, 1.2 or 1.3", opts.MinVersion, bind)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if len(opts.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range opts.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("Unknown TLS cipher suite %q for %v", name, bind)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+	if len(opts.ALPN) > 0 {
+		tlsConfig.NextProtos = opts.ALPN
+	}
+	return tlsConfig, nil
+}
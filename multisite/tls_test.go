@@ -0,0 +1,84 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"bytes"
+	"crypto/tls"
+	"github.com/robert-wallis/webd/config"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func Test_newServerSite_tlsAnyPort(t *testing.T) {
+	// GIVEN sites with an https bind that isn't on port 443
+	configs := []*config.Config{{
+		Host:   "secure.example.com",
+		Static: true,
+		Path:   "../test_data/secure.example.com",
+		Bind: config.ConfigBind{
+			HTTPS: "localhost:8443",
+			TLS: config.ConfigTLS{
+				MinVersion:            "1.2",
+				CipherSuites:          []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+				ALPN:                  []string{"http/1.1"},
+				DisableSessionTickets: true,
+			},
+		},
+	}, {
+		Host:   "files.example.com",
+		Static: true,
+		Path:   "../test_data/files.example.com",
+		Bind:   config.ConfigBind{HTTPS: "localhost:8443"},
+	}}
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+
+	// WHEN the server is made
+	s, err := newServerSite("localhost:8443", configs, nil, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	// THEN it serves TLS with the options
+	if !s.tlsEnabled {
+		t.Fatal("Expecting TLS on localhost:8443")
+	}
+	tlsConfig, _ := s.getConfigForClient(&tls.ClientHelloInfo{})
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expecting TLS 1.2 got %x", tlsConfig.MinVersion)
+	}
+	if !reflect.DeepEqual(tlsConfig.CipherSuites, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}) {
+		t.Errorf("Expecting the cipher suite got %v", tlsConfig.CipherSuites)
+	}
+	if !reflect.DeepEqual(tlsConfig.NextProtos, []string{"http/1.1"}) {
+		t.Errorf("Expecting http/1.1 got %v", tlsConfig.NextProtos)
+	}
+	if !tlsConfig.SessionTicketsDisabled {
+		t.Error("Expecting session tickets to be disabled")
+	}
+}
+
+func Test_tlsOptions_errors(t *testing.T) {
+	https := func(host string, opts config.ConfigTLS) *config.Config {
+		return &config.Config{Host: host, Bind: config.ConfigBind{HTTPS: ":8443", TLS: opts}}
+	}
+	type test struct {
+		configs []*config.Config
+		desc    string
+	}
+	tests := []test{
+		{[]*config.Config{https("a.example.com", config.ConfigTLS{MinVersion: "1.2"}), https("b.example.com", config.ConfigTLS{MinVersion: "1.3"})}, "different options"},
+		{[]*config.Config{https("a.example.com", config.ConfigTLS{MinVersion: "2"})}, "unknown version"},
+		{[]*config.Config{https("a.example.com", config.ConfigTLS{CipherSuites: []string{"NOPE"}})}, "unknown cipher"},
+		{[]*config.Config{https("a.example.com", config.ConfigTLS{}), {Host: "b.example.com", Bind: config.ConfigBind{HTTP: ":8443"}}}, "http and https"},
+	}
+	for i := range tests {
+		// GIVEN sites with bad TLS options, WHEN the bind is prepared THEN it should error
+		s := &serverSite{bind: ":8443", tlsEnabled: true}
+		if _, err := s.prepare(tests[i].configs); err == nil {
+			t.Errorf("Expecting an error for %v", tests[i].desc)
+		}
+	}
+}
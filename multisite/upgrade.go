@@ -0,0 +1,116 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+//go:build !windows
+// +build !windows
+
+package multisite
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpgradeTimeout is how long Upgrade waits for the new process to start serving.
+var UpgradeTimeout = 30 * time.Second
+
+// Upgrade starts a new webd process from the executable on disk, with the same arguments,
+// handing it the sockets of every server so no connection is refused while it starts.
+// It returns once the new process is serving, then the caller should Shutdown this process, letting its requests in flight finish.
+// If the new process exits, or isn't serving within UpgradeTimeout, it's stopped and this process should keep serving.
+func (m *MultiSite) Upgrade() (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	files, binds, err := m.listenerFiles()
+	defer func() {
+		for f := range files {
+			files[f].Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer ready.Close()
+	files = append(files, readyWriter)
+	env := []string{
+		listenersEnv + "=" + strings.Join(binds, ","),
+		readyEnv + "=" + strconv.Itoa(2+len(files)),
+	}
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, listenersEnv+"=") && !strings.HasPrefix(e, readyEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	m.infoLog.Println("upgrading, handing", strings.Join(binds, ", "), "to", executable)
+	p, err := os.StartProcess(executable, os.Args, &os.ProcAttr{
+		Env:   env,
+		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...),
+	})
+	// only the new process can write to it now, so it's closed if that exits
+	readyWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err = waitReady(ready, UpgradeTimeout); err != nil {
+		p.Kill()
+		go p.Wait()
+		return nil, fmt.Errorf("New process %v didn't start serving: %v", p.Pid, err)
+	}
+	return p, nil
+}
+
+// waitReady waits for the new process to write to the `ready` pipe, which it does once it's serving.
+func waitReady(ready *os.File, timeout time.Duration) error {
+	read := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		if err == io.EOF {
+			err = fmt.Errorf("it exited")
+		}
+		read <- err
+	}()
+	select {
+	case err := <-read:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
+// listenerFiles duplicates the sockets of the servers and the admin server, along with their binds.
+func (m *MultiSite) listenerFiles() (files []*os.File, binds []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	listeners := make(map[string]net.Listener)
+	for s := range m.sites {
+		if l := m.sites[s].Listener(); l != nil {
+			listeners[m.sites[s].bind] = l
+		}
+	}
+	if m.adminListener != nil {
+		listeners[m.adminBind] = m.adminListener
+	}
+	for bind, l := range listeners {
+		tcp, ok := l.(*net.TCPListener)
+		if !ok {
+			return files, binds, fmt.Errorf("Can't hand off %v, it's a %T", bind, l)
+		}
+		f, err := tcp.File()
+		if err != nil {
+			return files, binds, fmt.Errorf("Can't hand off %v: %v", bind, err)
+		}
+		files = append(files, f)
+		binds = append(binds, bind)
+	}
+	return
+}
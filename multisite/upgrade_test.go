@@ -0,0 +1,106 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+//go:build !windows
+// +build !windows
+
+package multisite
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_MultiSite_listenerFiles(t *testing.T) {
+	// GIVEN a multi-site serving one of its binds
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	ms, err := New("../test_data/combine_sites.yaml", false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	site := ms.sites[0]
+	site.mu.Lock()
+	site.listener = l
+	site.mu.Unlock()
+
+	// WHEN the sockets are collected for an upgrade
+	files, binds, err := ms.listenerFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer files[0].Close()
+
+	// THEN only the socket being served is handed off, with its bind
+	if len(files) != 1 || len(binds) != 1 {
+		t.Fatalf("Expecting 1 file and bind got %v and %v", len(files), binds)
+	}
+	if binds[0] != site.bind {
+		t.Errorf("Expecting bind %v got %v", site.bind, binds[0])
+	}
+	inherited, err := net.FileListener(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inherited.Close()
+	if inherited.Addr().String() != l.Addr().String() {
+		t.Errorf("Expecting %v got %v", l.Addr(), inherited.Addr())
+	}
+}
+
+func Test_waitReady(t *testing.T) {
+	// GIVEN a new process that signals it's serving
+	ready, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ready.Close()
+	os.Setenv(readyEnv, strconv.Itoa(int(w.Fd())))
+	if err := signalReady(); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the upgrade sees it's ready, and the variable isn't passed on
+	if err := waitReady(ready, time.Second); err != nil {
+		t.Errorf("Expecting ready got %v", err)
+	}
+	if _, ok := os.LookupEnv(readyEnv); ok {
+		t.Errorf("Expecting %v to be unset", readyEnv)
+	}
+}
+
+func Test_waitReady_not_ready(t *testing.T) {
+	// GIVEN a new process that exits before it's serving
+	exited, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exited.Close()
+	w.Close()
+
+	// THEN the upgrade fails
+	if err := waitReady(exited, time.Second); err == nil {
+		t.Error("Expecting an error when the process exits")
+	}
+
+	// GIVEN a new process that never gets to serving
+	stuck, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stuck.Close()
+	defer w.Close()
+
+	// THEN the upgrade times out
+	if err := waitReady(stuck, 10*time.Millisecond); err == nil {
+		t.Error("Expecting an error when the process isn't ready in time")
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package multisite
+
+import (
+	"fmt"
+	"os"
+)
+
+// Upgrade isn't possible on windows, sockets can't be inherited by a new process.
+func (m *MultiSite) Upgrade() (*os.Process, error) {
+	return nil, fmt.Errorf("Upgrade isn't supported on windows")
+}
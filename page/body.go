@@ -0,0 +1,30 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package page
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// BodyHTML renders the YAML body blocks and the markdown content as plain HTML, without a layout.
+// It's used where the page can't go through the site's templates, like feeds.
+func (p *Page) BodyHTML() template.HTML {
+	buf := &bytes.Buffer{}
+	for b := range p.Body {
+		block := p.Body[b]
+		for _, tag := range []string{"h1", "h2", "h3", "p"} {
+			if text, ok := block[tag]; ok && len(text) > 0 {
+				buf.WriteString("<" + tag + ">")
+				template.HTMLEscape(buf, []byte(text))
+				buf.WriteString("</" + tag + ">\n")
+			}
+		}
+		if html, ok := block["html"]; ok {
+			buf.WriteString(html)
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString(string(p.Content))
+	return template.HTML(buf.String())
+}
@@ -0,0 +1,26 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package page
+
+import "testing"
+
+func Test_Page_BodyHTML(t *testing.T) {
+	// GIVEN a page with body blocks and markdown content
+	p := &Page{
+		Body: []map[string]string{
+			{"h2": "A <heading>"},
+			{"p": "Some text."},
+			{"html": "<b>raw</b>"},
+		},
+		Content: "<p>markdown</p>\n",
+	}
+
+	// WHEN it's rendered
+	html := p.BodyHTML()
+
+	// THEN the text should be escaped and the html left alone
+	expected := "<h2>A &lt;heading&gt;</h2>\n<p>Some text.</p>\n<b>raw</b>\n<p>markdown</p>\n"
+	if string(html) != expected {
+		t.Errorf(`Expecting "%v" got "%v"`, expected, html)
+	}
+}
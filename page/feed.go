@@ -0,0 +1,211 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package page
+
+import (
+	"encoding/xml"
+	"html/template"
+	"net/url"
+	"time"
+)
+
+// DefaultFeedLimit is how many posts are in a feed when the index.yaml doesn't say.
+const DefaultFeedLimit = 20
+
+// Feed configures the RSS and Atom feeds of a directory in its index.yaml.
+//
+//	feed:
+//	  limit: 10
+//	  full: true
+type Feed struct {
+	Limit int  // the newest posts to include, 0 is DefaultFeedLimit
+	Full  bool // include the whole body of each post, otherwise just the subtitle as a summary
+}
+
+// FeedPages is the list of posts in the feed of this page, newest first.
+func (p *Page) FeedPages() []*Page {
+	list := p.Flatten()
+	limit := p.Feed.Limit
+	if limit <= 0 {
+		limit = DefaultFeedLimit
+	}
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders the RSS 2.0 feed of this page's posts, links are made absolute with `base`.
+func (p *Page) RSS(base *url.URL) ([]byte, error) {
+	pages := p.FeedPages()
+	feed := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       p.Title,
+			Link:        AbsoluteUrl(base, p.URL),
+			Description: p.SubTitle,
+		},
+	}
+	if updated := feedUpdated(p, pages); !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for i := range pages {
+		item := pages[i]
+		link := AbsoluteUrl(base, item.URL)
+		r := rssItem{
+			Title:       item.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			Description: string(p.feedDescription(base, item)),
+		}
+		if !item.DateUpdated.IsZero() {
+			r.PubDate = item.DateUpdated.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, r)
+	}
+	return marshalFeed(feed)
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string    `xml:"title"`
+	ID      string    `xml:"id"`
+	Updated string    `xml:"updated"`
+	Link    atomLink  `xml:"link"`
+	Summary *atomText `xml:"summary,omitempty"`
+	Content *atomText `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom renders the Atom feed of this page's posts, `self` is the url of the feed itself.
+// Atom needs a date for everything, `loaded` is used for pages without a `dateupdated`.
+func (p *Page) Atom(base *url.URL, self string, loaded time.Time) ([]byte, error) {
+	pages := p.FeedPages()
+	link := AbsoluteUrl(base, p.URL)
+	feed := atomFeed{
+		Title:    p.Title,
+		Subtitle: p.SubTitle,
+		ID:       link,
+		Updated:  orTime(feedUpdated(p, pages), loaded).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: link},
+			{Href: AbsoluteUrl(base, self), Rel: "self"},
+		},
+	}
+	for i := range pages {
+		item := pages[i]
+		itemLink := AbsoluteUrl(base, item.URL)
+		entry := atomEntry{
+			Title:   item.Title,
+			ID:      itemLink,
+			Updated: orTime(item.DateUpdated, loaded).Format(time.RFC3339),
+			Link:    atomLink{Href: itemLink},
+		}
+		text := &atomText{Type: "html", Body: string(p.feedDescription(base, item))}
+		if p.Feed.Full {
+			entry.Content = text
+		} else {
+			entry.Summary = text
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshalFeed(feed)
+}
+
+// feedDescription is the HTML for a post in the feed, the thumbnail then either the full body or the subtitle.
+func (p *Page) feedDescription(base *url.URL, item *Page) template.HTML {
+	var html template.HTML
+	if item.Thumbnail != "" {
+		html = template.HTML(`<p><img src="` + template.HTMLEscapeString(AbsoluteUrl(base, item.Thumbnail)) + `" alt=""/></p>` + "\n")
+	}
+	if p.Feed.Full {
+		return html + item.BodyHTML()
+	}
+	return html + template.HTML(template.HTMLEscapeString(item.SubTitle))
+}
+
+// feedUpdated is when the feed last changed, the date of the directory or its newest post.
+func feedUpdated(p *Page, pages []*Page) time.Time {
+	updated := p.DateUpdated
+	for i := range pages {
+		if pages[i].DateUpdated.After(updated) {
+			updated = pages[i].DateUpdated
+		}
+	}
+	return updated
+}
+
+// orTime is `t`, or `fallback` if `t` isn't set.
+func orTime(t, fallback time.Time) time.Time {
+	if t.IsZero() {
+		return fallback
+	}
+	return t
+}
+
+// AbsoluteUrl resolves `ref` against `base`, for feed readers and crawlers that can't resolve relative links.
+// The root is always written as /
+func AbsoluteUrl(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	u = base.ResolveReference(u)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+func marshalFeed(feed interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
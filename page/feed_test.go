@@ -0,0 +1,105 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package page
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Page_RSS(t *testing.T) {
+	// GIVEN a directory that limits its feed to 1 full post
+	base, _ := url.Parse("http://test/")
+	root, err := LoadRoot("test_content", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := MapPages(root)["/tree/"]
+
+	// WHEN the RSS is rendered
+	data, err := tree.RSS(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN it should have the newest post with the full body
+	feed := string(data)
+	expected := []string{
+		`<rss version="2.0">`,
+		"<title>Tree Test Dir</title>",
+		"<link>http://test/tree/</link>",
+		"<title>Pear Tree Test Page</title>",
+		`<guid isPermaLink="true">http://test/tree/pear/</guid>`,
+		"<pubDate>Sat, 20 Jan 2018 12:00:00 -0800</pubDate>",
+		"&lt;em&gt;sweet&lt;/em&gt;",
+	}
+	for i := range expected {
+		if !strings.Contains(feed, expected[i]) {
+			t.Errorf("Expecting %v in %v", expected[i], feed)
+		}
+	}
+	if strings.Contains(feed, "Apple") {
+		t.Errorf("Expecting the feed to be limited to 1 post %v", feed)
+	}
+}
+
+func Test_Page_Atom(t *testing.T) {
+	// GIVEN a directory with the default feed settings
+	base, _ := url.Parse("http://test/")
+	root, err := LoadRoot("test_content", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noindex := MapPages(root)["/noindex/"]
+
+	// WHEN the Atom feed is rendered
+	loaded := time.Date(2018, 1, 28, 12, 0, 0, 0, time.UTC)
+	data, err := noindex.Atom(base, "/noindex/atom.xml", loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN it should list visible posts with summaries
+	feed := string(data)
+	expected := []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<link href="http://test/noindex/atom.xml" rel="self"></link>`,
+		"<title>Some Thing</title>",
+		`<summary type="html">content that is in an autogenerated dir</summary>`,
+	}
+	for i := range expected {
+		if !strings.Contains(feed, expected[i]) {
+			t.Errorf("Expecting %v in %v", expected[i], feed)
+		}
+	}
+	if strings.Contains(feed, "Hidden Page") {
+		t.Errorf("Expecting listhidden pages to be left out %v", feed)
+	}
+
+	// THEN the posts without a dateupdated have the load time
+	if strings.Contains(feed, "0001-01-01") || !strings.Contains(feed, "<updated>2018-01-28T12:00:00Z</updated>") {
+		t.Errorf("Expecting the load time for posts without a date %v", feed)
+	}
+}
+
+func Test_AbsoluteUrl(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	type test struct {
+		ref      string
+		expected string
+	}
+	tests := []test{
+		{"/thumbs/trip.jpg", "https://example.com/thumbs/trip.jpg"},
+		{"http://other.com/a/", "http://other.com/a/"},
+		{"https://example.com/blog/", "https://example.com/blog/"},
+		{"https://example.com", "https://example.com/"},
+	}
+	for i := range tests {
+		got := AbsoluteUrl(base, tests[i].ref)
+		if got != tests[i].expected {
+			t.Errorf("%v expecting %v got %v", tests[i].ref, tests[i].expected, got)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"gopkg.in/yaml.v2"
 	"net/url"
 	"os"
+	"path/filepath"
 )
 
 func (p *Page) loadSubPage(filename string) (err error) {
@@ -30,7 +31,13 @@ func (p *Page) loadSubPage(filename string) (err error) {
 	if base == "index" {
 		subPage.Layout = "dir.html"
 	}
-	if err = yaml.Unmarshal(buf.Bytes(), subPage); err != nil {
+	data := buf.Bytes()
+	if filepath.Ext(filename) == ".md" {
+		var body []byte
+		data, body = splitFrontMatter(data)
+		subPage.Content = renderMarkdown(body)
+	}
+	if err = yaml.Unmarshal(data, subPage); err != nil {
 		err = fmt.Errorf("Couldn't decode yaml for page %v: %v", filename, err)
 		return
 	}
@@ -81,3 +81,40 @@ func Test_Page_loadPage_Layout(t *testing.T) {
 		}
 	}
 }
+
+func Test_Page_loadPage_markdown(t *testing.T) {
+	// GIVEN a folder
+	p := &Page{URL: "http://test/tree/"}
+
+	// WHEN a markdown page is loaded in that folder
+	if err := p.loadSubPage("test_content/tree/pear.md"); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.SubPages) != 1 {
+		t.Fatal("The sub-page was not added.")
+	}
+
+	// THEN the front matter should fill in the fields
+	sp := p.SubPages[0]
+	if sp.Title != "Pear Tree Test Page" {
+		t.Errorf("Expecting title from front matter got %v", sp.Title)
+	}
+	if sp.SubTitle != "written in markdown" {
+		t.Errorf("Expecting subtitle from front matter got %v", sp.SubTitle)
+	}
+	if sp.DateUpdated.Year() != 2018 {
+		t.Errorf("Expecting dateupdated from front matter got %v", sp.DateUpdated)
+	}
+	if sp.Layout != "page.html" {
+		t.Errorf("Expecting default layout got %v", sp.Layout)
+	}
+	if sp.URL != "http://test/tree/pear/" {
+		t.Errorf("Page URL was not correct: %v", sp.URL)
+	}
+
+	// THEN the markdown should be rendered
+	expected := "<h1>Pears</h1>\n\n<p>Pears are <em>sweet</em>.</p>\n"
+	if string(sp.Content) != expected {
+		t.Errorf(`Expecting content "%v" got "%v"`, expected, sp.Content)
+	}
+}
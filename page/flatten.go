@@ -10,7 +10,7 @@ func (p *Page) Flatten() (list []*Page) {
 	for len(todo) > 0 {
 		current := todo[0]
 		todo = todo[1:]
-		if current != p && (len(current.Body) != 0 || !current.Dir) && !current.ListHidden {
+		if current != p && (len(current.Body) != 0 || len(current.Content) != 0 || !current.Dir) && !current.ListHidden {
 			list = append(list, current)
 		}
 		for s := range current.SubPages {
@@ -40,7 +40,7 @@ func (p *Page) addAllPages(dir *os.File) (err error) {
 			}
 			continue
 		}
-		if filepath.Ext(filename) == ".yaml" {
+		if ext := filepath.Ext(filename); ext == ".yaml" || ext == ".md" {
 			err = p.loadSubPage(fullFilename)
 			if err != nil {
 				err = fmt.Errorf("Couldn't load page: %v %v", filename, err)
@@ -28,6 +28,7 @@ func Test_MapPages(t *testing.T) {
 		"/rock/",
 		"/tree/",
 		"/tree/apple/",
+		"/tree/pear/",
 	}
 
 	for i := range tests {
@@ -0,0 +1,45 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package page
+
+import (
+	"bytes"
+	"github.com/russross/blackfriday/v2"
+	"html/template"
+)
+
+// frontMatterFence starts and ends the YAML at the top of a markdown file.
+var frontMatterFence = []byte("---")
+
+// splitFrontMatter separates the YAML front matter from the markdown body.
+// The front matter is between two lines of `---` at the very top of the file, it's optional.
+func splitFrontMatter(data []byte) (frontMatter, body []byte) {
+	first, rest := cutLine(data)
+	if !bytes.Equal(bytes.TrimSpace(first), frontMatterFence) {
+		return nil, data
+	}
+	for len(rest) > 0 {
+		var line []byte
+		line, rest = cutLine(rest)
+		if bytes.Equal(bytes.TrimSpace(line), frontMatterFence) {
+			return frontMatter, rest
+		}
+		frontMatter = append(frontMatter, line...)
+		frontMatter = append(frontMatter, '\n')
+	}
+	// never closed, so it was just markdown
+	return nil, data
+}
+
+// cutLine returns the first line without the line ending, and everything after it.
+func cutLine(data []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return bytes.TrimSuffix(data[:i], []byte("\r")), data[i+1:]
+	}
+	return data, nil
+}
+
+// renderMarkdown turns the markdown body into HTML that templates can use as is.
+func renderMarkdown(body []byte) template.HTML {
+	return template.HTML(blackfriday.Run(body))
+}
@@ -0,0 +1,30 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package page
+
+import "testing"
+
+func Test_splitFrontMatter(t *testing.T) {
+	type test struct {
+		data        string
+		frontMatter string
+		body        string
+	}
+	tests := []test{
+		{"---\ntitle: A\n---\n# Body\n", "title: A\n", "# Body\n"},
+		{"---\r\ntitle: A\r\nlayout: b.html\r\n---\r\nBody", "title: A\nlayout: b.html\n", "Body"},
+		{"---\n---\nBody", "", "Body"},
+		{"# Just Markdown\n", "", "# Just Markdown\n"},
+		{"---\ntitle: never closed\n", "", "---\ntitle: never closed\n"},
+		{"", "", ""},
+	}
+	for i := range tests {
+		frontMatter, body := splitFrontMatter([]byte(tests[i].data))
+		if string(frontMatter) != tests[i].frontMatter {
+			t.Errorf("%q expecting front matter %q got %q", tests[i].data, tests[i].frontMatter, frontMatter)
+		}
+		if string(body) != tests[i].body {
+			t.Errorf("%q expecting body %q got %q", tests[i].data, tests[i].body, body)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package page
 
 import (
+	"html/template"
 	"time"
 )
 
@@ -20,7 +21,11 @@ type Page struct {
 	Layout      string
 	Redirects   []string
 	Body        []map[string]string
+	Content     template.HTML `yaml:"-"` // rendered from the markdown body of a .md page
 	ListHidden  bool
+	Feed        Feed // settings for the feeds of a directory
+	NoSitemap   bool // leave the page out of sitemap.xml
+	NoCache     bool // render the page on every request, for layouts that use the time of day
 }
 
 // copyIndex takes the contents of src and puts them in the page.
@@ -40,5 +45,9 @@ func (p *Page) copyIndex(src *Page) {
 	p.Layout = src.Layout
 	p.Redirects = src.Redirects
 	p.Body = src.Body
+	p.Content = src.Content
 	p.ListHidden = src.ListHidden
+	p.Feed = src.Feed
+	p.NoSitemap = src.NoSitemap
+	p.NoCache = src.NoCache
 }
@@ -26,6 +26,7 @@ func Test_Site_mapRedirects(t *testing.T) {
 	tests := []redirectTest{
 		{"/apple.html", "/tree/apple/"},
 		{"/apple/", "/tree/apple/"},
+		{"/pear/", "/tree/pear/"},
 	}
 
 	for i := range tests {
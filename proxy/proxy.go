@@ -0,0 +1,208 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+// Package to serve a site from the servers of an app, like a Go or Node server on another port.
+package proxy
+
+import (
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for the options that aren't set.
+const (
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultIdleTimeout         = 90 * time.Second
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// Handler sends each request to the next healthy upstream in turn.
+// The upstream gets the client's Host, and X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto.
+// WebSocket and other upgraded connections are passed through.
+type Handler struct {
+	upstreams []*upstream
+	next      uint32 // turns taken, for round robin
+	health    config.ConfigHealthCheck
+	client    *http.Client // for the health checks
+	infoLog   *log.Logger
+	errLog    *log.Logger
+	stop      chan struct{}
+	done      sync.WaitGroup
+}
+
+// upstream is one of the servers of the app.
+type upstream struct {
+	url     *url.URL
+	proxy   *httputil.ReverseProxy
+	healthy int32 // 1 when the last health check passed, or there aren't any
+}
+
+// New checks the upstreams of `cfg` and starts their health checks, Close stops them.
+func New(cfg config.ConfigProxy, infoLog, errLog *log.Logger) (*Handler, error) {
+	urls, err := upstreamURLs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		health:  cfg.HealthCheck,
+		infoLog: infoLog,
+		errLog:  errLog,
+		stop:    make(chan struct{}),
+	}
+	transport := newTransport(cfg)
+	for _, u := range urls {
+		up := &upstream{url: u, healthy: 1}
+		up.proxy = httputil.NewSingleHostReverseProxy(u)
+		up.proxy.Transport = transport
+		director := up.proxy.Director
+		up.proxy.Director = func(req *http.Request) {
+			forwarded(req)
+			director(req)
+		}
+		up.proxy.ErrorHandler = h.proxyError
+		h.upstreams = append(h.upstreams, up)
+	}
+	if len(h.health.Path) > 0 {
+		if h.health.Interval <= 0 {
+			h.health.Interval = DefaultHealthCheckInterval
+		}
+		if h.health.Timeout <= 0 {
+			h.health.Timeout = DefaultHealthCheckTimeout
+		}
+		h.client = &http.Client{Transport: transport, Timeout: h.health.Timeout}
+		h.done.Add(1)
+		go h.checkHealth()
+	}
+	return h, nil
+}
+
+// Check reports upstreams that aren't http or https urls, without starting anything.
+func Check(cfg config.ConfigProxy) error {
+	_, err := upstreamURLs(cfg)
+	return err
+}
+
+// upstreamURLs parses the upstreams of `cfg`, there has to be at least one.
+func upstreamURLs(cfg config.ConfigProxy) (urls []*url.URL, err error) {
+	if len(cfg.Upstreams) == 0 {
+		return nil, fmt.Errorf("No proxy upstreams")
+	}
+	for _, raw := range cfg.Upstreams {
+		var u *url.URL
+		if u, err = url.Parse(raw); err != nil {
+			return nil, fmt.Errorf("Bad proxy upstream %q: %v", raw, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("Bad proxy upstream %q, expecting http://host:port or https://host:port", raw)
+		}
+		urls = append(urls, u)
+	}
+	return
+}
+
+// newTransport is http.DefaultTransport with the timeouts of `cfg`.
+func newTransport(cfg config.ConfigProxy) *http.Transport {
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = cfg.ResponseTimeout
+	transport.IdleConnTimeout = idleTimeout
+	return transport
+}
+
+// forwarded tells the upstream the host and scheme the client asked for, X-Forwarded-For is added by the proxy.
+func forwarded(req *http.Request) {
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	req.Header.Set("X-Forwarded-Proto", proto)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.pick().proxy.ServeHTTP(w, req)
+}
+
+// pick is the next healthy upstream, or the next one when none of them are healthy, in case the checks are wrong.
+func (h *Handler) pick() *upstream {
+	n := len(h.upstreams)
+	start := int(atomic.AddUint32(&h.next, 1) - 1)
+	for i := 0; i < n; i++ {
+		up := h.upstreams[(start+i)%n]
+		if atomic.LoadInt32(&up.healthy) == 1 {
+			return up
+		}
+	}
+	return h.upstreams[start%n]
+}
+
+// proxyError answers when the upstream couldn't be reached, or didn't answer in time.
+func (h *Handler) proxyError(w http.ResponseWriter, req *http.Request, err error) {
+	h.errLog.Println(502, req.Host, req.URL, "Proxy", err)
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// checkHealth asks every upstream for the health check path until Close.
+func (h *Handler) checkHealth() {
+	defer h.done.Done()
+	ticker := time.NewTicker(h.health.Interval)
+	defer ticker.Stop()
+	for {
+		for _, up := range h.upstreams {
+			h.checkUpstream(up)
+		}
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkUpstream marks `up` healthy or not, and logs when that changes.
+func (h *Handler) checkUpstream(up *upstream) {
+	u := *up.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(h.health.Path, "/")
+	var healthy int32
+	resp, err := h.client.Get(u.String())
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 400 {
+			healthy = 1
+		} else {
+			err = fmt.Errorf("status %v", resp.StatusCode)
+		}
+	}
+	if atomic.SwapInt32(&up.healthy, healthy) == healthy {
+		return
+	}
+	if healthy == 1 {
+		h.infoLog.Println("Proxy upstream", up.url, "is healthy")
+	} else {
+		h.errLog.Println("Proxy upstream", up.url, "is unhealthy:", err)
+	}
+}
+
+// Close stops the health checks.
+func (h *Handler) Close() error {
+	close(h.stop)
+	h.done.Wait()
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/robert-wallis/webd/config"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testLog = log.New(ioutil.Discard, "", 0)
+
+// newUpstream is an app that answers with its name and the headers it got.
+func newUpstream(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%v %v host=%v forwarded-host=%v proto=%v for=%v", name, req.URL.Path,
+			req.Host, req.Header.Get("X-Forwarded-Host"), req.Header.Get("X-Forwarded-Proto"), req.Header.Get("X-Forwarded-For"))
+	}))
+}
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func Test_Handler_forward(t *testing.T) {
+	// GIVEN a proxy to an app with a base path
+	app := newUpstream("app")
+	defer app.Close()
+	h, err := New(config.ConfigProxy{Upstreams: []string{app.URL + "/base/"}}, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// WHEN a request comes in
+	w := get(t, h, "http://example.com/hello")
+
+	// THEN the app gets it under its path, with the client's host and the forwarded headers
+	expected := "app /base/hello host=example.com forwarded-host=example.com proto=http for=192.0.2.1"
+	if w.Code != 200 || w.Body.String() != expected {
+		t.Errorf("Expecting %q got %v %q", expected, w.Code, w.Body.String())
+	}
+}
+
+func Test_Handler_roundRobin(t *testing.T) {
+	// GIVEN a proxy to two apps
+	one, two := newUpstream("one"), newUpstream("two")
+	defer one.Close()
+	defer two.Close()
+	h, err := New(config.ConfigProxy{Upstreams: []string{one.URL, two.URL}}, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// WHEN requests come in THEN they take turns
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, strings.Fields(get(t, h, "http://example.com/").Body.String())[0])
+	}
+	if strings.Join(got, ",") != "one,two,one,two" {
+		t.Errorf("Expecting one,two,one,two got %v", got)
+	}
+}
+
+func Test_Handler_healthCheck(t *testing.T) {
+	// GIVEN two apps, one of them failing its health check
+	var sick int32 = 1
+	one := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/health" && atomic.LoadInt32(&sick) == 1 {
+			http.Error(w, "sick", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "one")
+	}))
+	defer one.Close()
+	two := newUpstream("two")
+	defer two.Close()
+	cfg := config.ConfigProxy{
+		Upstreams:   []string{one.URL, two.URL},
+		HealthCheck: config.ConfigHealthCheck{Path: "/health", Interval: 10 * time.Millisecond},
+	}
+	h, err := New(cfg, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	waitFor(t, func() bool { return atomic.LoadInt32(&h.upstreams[0].healthy) == 0 })
+
+	// WHEN requests come in THEN only the healthy one gets them
+	for i := 0; i < 4; i++ {
+		if got := get(t, h, "http://example.com/").Body.String(); !strings.HasPrefix(got, "two") {
+			t.Errorf("Expecting two got %q", got)
+		}
+	}
+
+	// WHEN it gets better THEN it gets requests again
+	atomic.StoreInt32(&sick, 0)
+	waitFor(t, func() bool { return atomic.LoadInt32(&h.upstreams[0].healthy) == 1 })
+	bodies := get(t, h, "http://example.com/").Body.String() + get(t, h, "http://example.com/").Body.String()
+	if !strings.Contains(bodies, "one") {
+		t.Errorf("Expecting one to answer again got %q", bodies)
+	}
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	for i := 0; i < 200; i++ {
+		if done() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out")
+}
+
+func Test_Handler_badGateway(t *testing.T) {
+	// GIVEN an upstream that isn't running
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	errLog := &bytes.Buffer{}
+	h, err := New(config.ConfigProxy{Upstreams: []string{down.URL}}, testLog, log.New(errLog, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// WHEN a request comes in THEN it's a 502, and logged
+	if w := get(t, h, "http://example.com/"); w.Code != http.StatusBadGateway {
+		t.Errorf("Expecting 502 got %v", w.Code)
+	}
+	if !strings.Contains(errLog.String(), "502") {
+		t.Errorf("Expecting the error to be logged got %q", errLog.String())
+	}
+}
+
+func Test_Handler_upgrade(t *testing.T) {
+	// GIVEN an app that upgrades to an echo protocol
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		rw.Flush()
+		io.Copy(conn, rw)
+	}))
+	defer app.Close()
+	h, err := New(config.ConfigProxy{Upstreams: []string{app.URL}}, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	front := httptest.NewServer(h)
+	defer front.Close()
+
+	// WHEN a client upgrades through the proxy
+	conn, err := net.Dial("tcp", strings.TrimPrefix(front.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN it's switched, and the connection goes both ways
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expecting 101 got %v", resp.StatusCode)
+	}
+	fmt.Fprint(conn, "ping\n")
+	line, err := r.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("Expecting the echo got %q %v", line, err)
+	}
+}
+
+func Test_New_errors(t *testing.T) {
+	for _, upstreams := range [][]string{nil, {"127.0.0.1:8080"}, {"ftp://example.com"}, {"http://%zz"}} {
+		if err := Check(config.ConfigProxy{Upstreams: upstreams}); err == nil {
+			t.Errorf("Expecting an error for %v", upstreams)
+		}
+	}
+}
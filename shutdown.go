@@ -0,0 +1,63 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serveUntilSignal runs `serve` until it fails, or until SIGINT or SIGTERM asks it to stop.
+// On a signal `shutdown` gets `timeout` to let requests in flight finish,
+// and its error is returned as `shutdownErr`, like context.DeadlineExceeded when they didn't.
+// If `upgrade` isn't nil, the upgradeSignal starts a new process with it, then shuts down the same way.
+func serveUntilSignal(serve func() error, shutdown func(context.Context) error, upgrade func() error, timeout time.Duration, infoLog, errorLog *log.Logger) (serveErr, shutdownErr error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	upgrades := make(chan os.Signal, 1)
+	if upgrade != nil && upgradeSignal != nil {
+		signal.Notify(upgrades, upgradeSignal)
+		defer signal.Stop(upgrades)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serve()
+	}()
+
+	for stopping := false; !stopping; {
+		select {
+		case serveErr = <-served:
+			return filterClosed(serveErr), nil
+		case sig := <-signals:
+			infoLog.Println("shutting down on", sig, "waiting up to", timeout, "for requests to finish")
+			stopping = true
+		case sig := <-upgrades:
+			if err := upgrade(); err != nil {
+				errorLog.Println("Error: upgrade on", sig, err)
+				continue
+			}
+			infoLog.Println("upgraded on", sig, "waiting up to", timeout, "for requests to finish")
+			stopping = true
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	shutdownErr = shutdown(ctx)
+	serveErr = <-served
+	return filterClosed(serveErr), shutdownErr
+}
+
+// filterClosed ignores the error a server returns because it was shut down.
+func filterClosed(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_serveUntilSignal(t *testing.T) {
+	// GIVEN a server that gets a SIGTERM once it's serving
+	stopped := make(chan struct{})
+	serve := func() error {
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		<-stopped
+		return http.ErrServerClosed
+	}
+	var deadline bool
+	shutdown := func(ctx context.Context) error {
+		_, deadline = ctx.Deadline()
+		close(stopped)
+		return nil
+	}
+
+	// WHEN it's served until the signal
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	serveErr, shutdownErr := serveUntilSignal(serve, shutdown, nil, time.Second, testLog, testLog)
+
+	// THEN it should be shut down with a deadline, and closing the server isn't an error
+	if !deadline {
+		t.Error("Expecting shutdown to have a deadline")
+	}
+	if serveErr != nil || shutdownErr != nil {
+		t.Errorf("Expecting no errors got %v and %v", serveErr, shutdownErr)
+	}
+}
+
+func Test_serveUntilSignal_upgrade(t *testing.T) {
+	// GIVEN a server that gets the upgrade signal once it's serving
+	stopped := make(chan struct{})
+	serve := func() error {
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+		<-stopped
+		return nil
+	}
+	shutdown := func(ctx context.Context) error {
+		close(stopped)
+		return nil
+	}
+	upgraded := false
+	upgrade := func() error {
+		upgraded = true
+		return nil
+	}
+
+	// WHEN it's served until the signal
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	serveUntilSignal(serve, shutdown, upgrade, time.Second, testLog, testLog)
+
+	// THEN it should upgrade, then shut down
+	if !upgraded {
+		t.Error("Expecting an upgrade")
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImmutableCacheControl is sent with fingerprinted static files, their content never changes under the same name.
+const ImmutableCacheControl = "public, max-age=31536000, immutable"
+
+// assetHashLength is how many hex digits of the sha256 of a static file are in its fingerprinted name.
+const assetHashLength = 10
+
+// assets fingerprints the static files the layouts link to, /css/index.css is /css/index.<hash>.css.
+type assets struct {
+	dir    string
+	prefix string // the path the site is mounted under, without the last /
+	mu     sync.Mutex
+	hashes map[string]assetHash // by url path, like /css/index.css
+}
+
+// assetHash is the hash of a file when it had that size and time, so it's only read again when it changes.
+type assetHash struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+func newAssets(dir, prefix string) *assets {
+	return &assets{
+		dir:    dir,
+		prefix: prefix,
+		hashes: make(map[string]assetHash),
+	}
+}
+
+// url is the fingerprinted url of the static file at `name`, for the `asset` template function.
+func (a *assets) url(name string) (string, error) {
+	name = path.Clean("/" + name)
+	hash, err := a.hash(name)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't fingerprint asset %v: %v", name, err)
+	}
+	ext := path.Ext(name)
+	return a.prefix + strings.TrimSuffix(name, ext) + "." + hash + ext, nil
+}
+
+// hash is the start of the sha256 of the static file at `name`, as hex.
+func (a *assets) hash(name string) (string, error) {
+	file := filepath.Join(a.dir, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%v is a folder", name)
+	}
+	a.mu.Lock()
+	known, ok := a.hashes[name]
+	a.mu.Unlock()
+	if ok && known.size == info.Size() && known.modTime.Equal(info.ModTime()) {
+		return known.hash, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err = io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	known = assetHash{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		hash:    hex.EncodeToString(sum.Sum(nil))[:assetHashLength],
+	}
+	a.mu.Lock()
+	a.hashes[name] = known
+	a.mu.Unlock()
+	return known.hash, nil
+}
+
+// lookup finds the static file a fingerprinted url is for.
+// `current` is false when the file has changed since, it's still served but can't be cached forever.
+func (a *assets) lookup(urlPath string) (name string, current, found bool) {
+	dir, base := path.Split(urlPath)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	hash := path.Ext(stem)
+	if isAssetHash(strings.TrimPrefix(hash, ".")) {
+		name = dir + strings.TrimSuffix(stem, hash) + ext
+	} else if isAssetHash(strings.TrimPrefix(ext, ".")) {
+		// a file without an extension, like /LICENSE.<hash>
+		hash, name = ext, dir+stem
+	} else {
+		return "", false, false
+	}
+	latest, err := a.hash(name)
+	if err != nil {
+		return "", false, false
+	}
+	return name, latest == hash[1:], true
+}
+
+// export copies each static file the layouts fingerprinted to its fingerprinted name in `outDir`.
+func (a *assets) export(outDir string) error {
+	a.mu.Lock()
+	var names []string
+	for name := range a.hashes {
+		names = append(names, name)
+	}
+	a.mu.Unlock()
+	for _, name := range names {
+		hashed, err := a.url(name)
+		if err != nil {
+			return err
+		}
+		hashed = strings.TrimPrefix(hashed, a.prefix)
+		data, err := ioutil.ReadFile(filepath.Join(a.dir, filepath.FromSlash(name)))
+		if err != nil {
+			return fmt.Errorf("Couldn't read asset %v: %v", name, err)
+		}
+		if err = writeExportFile(outDir, filepath.FromSlash(hashed), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isAssetHash is true for the hash in a fingerprinted name.
+func isAssetHash(s string) bool {
+	if len(s) != assetHashLength {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// CacheControl sets the Cache-Control header of every response of `next`, unless it's empty.
+func CacheControl(next http.Handler, value string) http.Handler {
+	if len(value) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", value)
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_assets(t *testing.T) {
+	// GIVEN a static folder
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "css"), 0755)
+	css := filepath.Join(dir, "css", "index.css")
+	ioutil.WriteFile(css, []byte("body { color: red; }"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "LICENSE"), []byte("MIT"), 0644)
+	a := newAssets(dir, "")
+
+	// WHEN a file is fingerprinted THEN the hash is before the extension
+	hashed, err := a.url("/css/index.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^/css/index\.[0-9a-f]{10}\.css$`).MatchString(hashed) {
+		t.Errorf("Expecting /css/index.<hash>.css got %v", hashed)
+	}
+	license, err := a.url("LICENSE")
+	if err != nil || !regexp.MustCompile(`^/LICENSE\.[0-9a-f]{10}$`).MatchString(license) {
+		t.Errorf("Expecting /LICENSE.<hash> got %v %v", license, err)
+	}
+	if _, err = a.url("/css/noexist.css"); err == nil {
+		t.Error("Expecting an error for a missing file")
+	}
+
+	// WHEN the fingerprinted urls are looked up THEN they're the files, and current
+	if name, current, found := a.lookup(hashed); name != "/css/index.css" || !current || !found {
+		t.Errorf("Expecting /css/index.css current got %v %v %v", name, current, found)
+	}
+	if name, current, found := a.lookup(license); name != "/LICENSE" || !current || !found {
+		t.Errorf("Expecting /LICENSE current got %v %v %v", name, current, found)
+	}
+	for _, urlPath := range []string{"/css/index.css", "/css/index.0123456789.js", "/css/index.nothexhash.css"} {
+		if _, _, found := a.lookup(urlPath); found {
+			t.Errorf("Expecting %v to not be an asset", urlPath)
+		}
+	}
+
+	// WHEN the file changes THEN it has a new url, and the old one isn't current
+	ioutil.WriteFile(css, []byte("body { color: blue; }"), 0644)
+	os.Chtimes(css, time.Now(), time.Now().Add(time.Minute))
+	changed, err := a.url("/css/index.css")
+	if err != nil || changed == hashed {
+		t.Errorf("Expecting a new url got %v %v", changed, err)
+	}
+	if _, current, found := a.lookup(hashed); current || !found {
+		t.Errorf("Expecting the old url to be found but not current got %v %v", current, found)
+	}
+}
+
+func Test_Site_staticHandler_asset(t *testing.T) {
+	// GIVEN a site whose layouts fingerprint the stylesheet, and a cache policy for the other files
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetCacheControl("public, max-age=60")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", address.String()+"/", nil))
+	hashed := regexp.MustCompile(`/css/index\.[0-9a-f]{10}\.css`).FindString(w.Body.String())
+	if len(hashed) == 0 {
+		t.Fatal("Expecting the page to link to the fingerprinted stylesheet")
+	}
+
+	// WHEN the fingerprinted stylesheet is asked for THEN it's the file, cached forever
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", address.String()+hashed, nil))
+	if w.Code != 200 || !strings.Contains(w.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Expecting the css got %v %v", w.Code, w.Header().Get("Content-Type"))
+	}
+	if got := w.Header().Get("Cache-Control"); got != ImmutableCacheControl {
+		t.Errorf("Expecting %q got %q", ImmutableCacheControl, got)
+	}
+
+	// WHEN the file is asked for by its own name THEN it gets the site's policy
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", address.String()+"/css/index.css", nil))
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("Expecting the site's Cache-Control got %q", got)
+	}
+
+	// WHEN an unknown hash is asked for THEN it's the current file, but not cached forever
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", address.String()+"/css/index.0000000000.css", nil))
+	if w.Code != 200 || w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Errorf("Expecting the css with the site's Cache-Control got %v %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/robert-wallis/webd/page"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedPage is a page rendered once, until the templates and content are reloaded or the day changes.
+type cachedPage struct {
+	body     []byte
+	etag     string
+	modified time.Time
+	rendered time.Time
+}
+
+// pageCache holds the rendered pages of a site by path.
+type pageCache struct {
+	mu     sync.Mutex
+	pages  map[string]*cachedPage
+	loaded time.Time // when the templates and content were loaded, pages are never older
+}
+
+func newPageCache(loaded time.Time) *pageCache {
+	return &pageCache{
+		pages:  make(map[string]*cachedPage),
+		loaded: loaded,
+	}
+}
+
+// cachedPage returns `p` rendered for `path` with the templates of `site`, rendering it the first time each day,
+// so layouts that show the date or year with the `time` function stay current.
+func (s *Site) cachedPage(site *content, path string, p *page.Page) (*cachedPage, error) {
+	c := site.cache
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.pages[path]
+	c.mu.Unlock()
+	if ok && sameDay(cached.rendered, now) {
+		return cached, nil
+	}
+	buf := &bytes.Buffer{}
+	if err := site.writePage(buf, p, ""); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	cached = &cachedPage{
+		body:     buf.Bytes(),
+		etag:     fmt.Sprintf(`"%x"`, sum[:16]),
+		modified: p.DateUpdated,
+		rendered: now,
+	}
+	// a reload may have changed the layouts, partials or data even if the page didn't change, and so may the day
+	oldest := c.loaded
+	if today := startOfDay(now); today.After(oldest) {
+		oldest = today
+	}
+	if cached.modified.Before(oldest) {
+		cached.modified = oldest
+	}
+	c.mu.Lock()
+	c.pages[path] = cached
+	c.mu.Unlock()
+	return cached, nil
+}
+
+// sameDay is true if `a` and `b` are on the same local date.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// startOfDay is midnight, local time, of the day of `t`.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// serve sends the page, or 304 Not Modified if the client already has it.
+func (c *cachedPage) serve(w http.ResponseWriter, req *http.Request) error {
+	header := w.Header()
+	header.Set("ETag", c.etag)
+	header.Set("Last-Modified", c.modified.UTC().Format(http.TimeFormat))
+	if c.notModified(req) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if len(header.Get("Content-Type")) == 0 {
+		header.Set("Content-Type", http.DetectContentType(c.body))
+	}
+	header.Set("Content-Length", strconv.Itoa(len(c.body)))
+	if req.Method == http.MethodHead {
+		return nil
+	}
+	_, err := w.Write(c.body)
+	return err
+}
+
+// notModified is true if the client's copy is still good, If-None-Match wins over If-Modified-Since.
+func (c *cachedPage) notModified(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if match := req.Header.Get("If-None-Match"); len(match) > 0 {
+		for _, etag := range strings.Split(match, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == "*" || etag == c.etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !c.modified.Truncate(time.Second).After(since)
+}
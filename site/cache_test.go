@@ -0,0 +1,172 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newCacheTestSite(t *testing.T) *Site {
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_Site_ServeHTTP_cache(t *testing.T) {
+	// GIVEN a site
+	s := newCacheTestSite(t)
+
+	// WHEN a page is asked for twice
+	first := httptest.NewRecorder()
+	s.ServeHTTP(first, httptest.NewRequest("GET", "http://localhost:8009/", nil))
+	second := httptest.NewRecorder()
+	s.ServeHTTP(second, httptest.NewRequest("GET", "http://localhost:8009/", nil))
+
+	// THEN both have the same strong ETag and body
+	etag := first.Header().Get("ETag")
+	if len(etag) == 0 || etag[0] != '"' {
+		t.Fatalf("Expecting a strong ETag got %q", etag)
+	}
+	if got := second.Header().Get("ETag"); got != etag {
+		t.Errorf("Expecting the ETag %v again got %v", etag, got)
+	}
+	if first.Body.String() != second.Body.String() || first.Body.Len() == 0 {
+		t.Error("Expecting the same body from the cache")
+	}
+	if len(first.Header().Get("Last-Modified")) == 0 {
+		t.Error("Expecting Last-Modified")
+	}
+
+	// WHEN the client has the ETag THEN it's not modified
+	req := httptest.NewRequest("GET", "http://localhost:8009/", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expecting 304 with no body got %v %q", w.Code, w.Body.String())
+	}
+
+	// WHEN the client has an old ETag THEN it gets the page
+	req = httptest.NewRequest("GET", "http://localhost:8009/", nil)
+	req.Header.Set("If-None-Match", `"old"`)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expecting 200 got %v", w.Code)
+	}
+
+	// WHEN the client's copy is newer than the page THEN it's not modified
+	req = httptest.NewRequest("GET", "http://localhost:8009/", nil)
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expecting 304 got %v", w.Code)
+	}
+
+	// WHEN the client's copy is older THEN it gets the page
+	req = httptest.NewRequest("GET", "http://localhost:8009/", nil)
+	req.Header.Set("If-Modified-Since", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expecting 200 got %v", w.Code)
+	}
+}
+
+func Test_Site_ServeHTTP_nocache(t *testing.T) {
+	// GIVEN a page that opts out of the cache
+	s := newCacheTestSite(t)
+	s.current().pageMap["/"].NoCache = true
+
+	// WHEN it's asked for
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8009/", nil))
+
+	// THEN it's rendered without an ETag, and isn't kept
+	if w.Code != http.StatusOK {
+		t.Errorf("Expecting 200 got %v", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != "" {
+		t.Errorf("Expecting no ETag got %v", got)
+	}
+	if len(s.current().cache.pages) != 0 {
+		t.Errorf("Expecting an empty cache got %v pages", len(s.current().cache.pages))
+	}
+}
+
+func Test_Site_cache_reload(t *testing.T) {
+	// GIVEN a site with a cached page
+	s := newCacheTestSite(t)
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8009/", nil))
+	if len(s.current().cache.pages) != 1 {
+		t.Fatalf("Expecting 1 cached page got %v", len(s.current().cache.pages))
+	}
+
+	// WHEN the site is reloaded
+	if err := s.loadTemplatesAndContent(); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the cache is empty
+	if len(s.current().cache.pages) != 0 {
+		t.Errorf("Expecting an empty cache got %v pages", len(s.current().cache.pages))
+	}
+}
+
+func Test_Site_cache_day(t *testing.T) {
+	// GIVEN a page cached yesterday, before the reload that loaded it
+	s := newCacheTestSite(t)
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8009/", nil))
+	c := s.current().cache
+	yesterday := time.Now().AddDate(0, 0, -1)
+	c.loaded = yesterday
+	cached := c.pages["/"]
+	cached.rendered, cached.modified = yesterday, yesterday
+
+	// WHEN the client asks if it changed since yesterday
+	req := httptest.NewRequest("GET", "http://localhost:8009/", nil)
+	req.Header.Set("If-Modified-Since", yesterday.UTC().Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	// THEN the page is rendered again for today, for layouts that show the date
+	if w.Code != http.StatusOK {
+		t.Errorf("Expecting 200 got %v", w.Code)
+	}
+	if c.pages["/"] == cached || !sameDay(c.pages["/"].rendered, time.Now()) {
+		t.Error("Expecting the page to be rendered again today")
+	}
+}
+
+func Test_Site_cache_reload_modified(t *testing.T) {
+	// GIVEN a page with a dateupdated, served before a reload that changed its layout
+	s := newCacheTestSite(t)
+	s.current().cache.loaded = time.Now().Add(-time.Hour)
+	before := httptest.NewRecorder()
+	s.ServeHTTP(before, httptest.NewRequest("GET", "http://localhost:8009/blog/mixbody/", nil))
+	if err := s.loadTemplatesAndContent(); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN the client asks if it changed since its copy
+	req := httptest.NewRequest("GET", "http://localhost:8009/blog/mixbody/", nil)
+	req.Header.Set("If-Modified-Since", before.Header().Get("Last-Modified"))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	// THEN it gets the page rendered with the new layout
+	if before.Code != http.StatusOK || w.Code != http.StatusOK {
+		t.Errorf("Expecting 200 before and after the reload got %v and %v", before.Code, w.Code)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// Check reports what would stop a site in `templatePath` from working, without loading its content:
+// a missing `layouts`, `content` or `static` folder, and layouts that don't parse.
+func Check(templatePath string) (problems []error) {
+	layouts := true
+	for _, folder := range []string{"layouts", "content", "static"} {
+		folderPath := path.Join(templatePath, folder)
+		if info, err := os.Stat(folderPath); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("Missing %v folder %v", folder, folderPath))
+			layouts = layouts && folder != "layouts"
+		}
+	}
+	if !layouts {
+		return
+	}
+	if _, err := parseTemplates(templatePath, newAssets(path.Join(templatePath, "static"), "")); err != nil {
+		problems = append(problems, fmt.Errorf("Couldn't parse the layouts in %v: %v", templatePath, err))
+	}
+	return
+}
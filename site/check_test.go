@@ -0,0 +1,47 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Check(t *testing.T) {
+	// GIVEN the example site, THEN it's fine
+	if problems := Check(_templatePath); len(problems) > 0 {
+		t.Errorf("Expecting no problems got %v", problems)
+	}
+
+	// GIVEN a site without a static folder, and a layout that doesn't parse
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, folder := range []string{"layouts", "content"} {
+		if err = os.Mkdir(filepath.Join(dir, folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "layouts", "page.html"), []byte("{{ .Title "), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN it's checked
+	problems := Check(dir)
+
+	// THEN both are reported
+	if len(problems) != 2 {
+		t.Fatalf("Expecting 2 problems got %v", problems)
+	}
+	if !strings.Contains(problems[0].Error(), "Missing static folder") {
+		t.Errorf("Expecting the static folder to be missing got %v", problems[0])
+	}
+	if !strings.Contains(problems[1].Error(), "page.html") {
+		t.Errorf("Expecting a parse error in page.html got %v", problems[1])
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Export renders every page to `outDir`/path/index.html, copies the static files and the fingerprinted ones the pages use,
+// writes the sitemap, robots.txt and feeds the site generates, and a meta-refresh page for each redirect as well as a `_redirects` file listing all of them.
+// A page with a template error doesn't stop the export, all of them are listed in the error at the end.
+func (s *Site) Export(outDir string) (err error) {
+	c := s.current()
+	if err = copyFolder(s.staticPath, outDir); err != nil {
+		return
+	}
+
+	var failed []string
+	for urlPath, p := range c.pageMap {
+		buf := &bytes.Buffer{}
+		if err = c.writePage(buf, p, ""); err != nil {
+			s.errLog.Println("Export", urlPath, "Template Execute", err)
+			failed = append(failed, urlPath)
+			continue
+		}
+		if err = writeExportFile(outDir, indexFile(urlPath), buf.Bytes()); err != nil {
+			return
+		}
+		if urlPath == "/404/" {
+			// static hosts look for this one at the top
+			if err = writeExportFile(outDir, "404.html", buf.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = s.assets.export(outDir); err != nil {
+		return
+	}
+
+	if err = s.exportSitemap(outDir); err != nil {
+		return
+	}
+
+	if err = s.exportFeeds(c, outDir); err != nil {
+		return
+	}
+
+	if err = s.exportRedirects(c, outDir); err != nil {
+		return
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("Couldn't export %d pages: %v", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// exportSitemap writes sitemap.xml, the sitemap-N.xml files it lists when there are too many pages for one,
+// and robots.txt if `static` doesn't have one.
+func (s *Site) exportSitemap(outDir string) error {
+	if _, err := s.exportGenerated(outDir, "/robots.txt", s.robotsHandler); err != nil {
+		return err
+	}
+	if _, err := s.exportGenerated(outDir, "/sitemap.xml", s.sitemapHandler); err != nil {
+		return err
+	}
+	for n := 1; ; n++ {
+		exported, err := s.exportGenerated(outDir, fmt.Sprintf("/sitemap-%d.xml", n), s.sitemapHandler)
+		if err != nil || !exported {
+			return err
+		}
+	}
+}
+
+// exportFeeds writes the feed.xml and atom.xml of every directory.
+func (s *Site) exportFeeds(c *content, outDir string) error {
+	for urlPath, p := range c.pageMap {
+		if !(p.Dir || p.Parent == nil) {
+			continue
+		}
+		for _, name := range []string{"feed.xml", "atom.xml"} {
+			if _, err := s.exportGenerated(outDir, s.sitePath(urlPath)+name, s.feedHandler); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportGenerated writes what `handler` serves for `localPath`, a path within the site, unless `static` has that file.
+// It's false if the handler doesn't serve the path.
+func (s *Site) exportGenerated(outDir, localPath string, handler func(http.ResponseWriter, *http.Request) bool) (bool, error) {
+	if _, err := os.Stat(filepath.Join(s.staticPath, filepath.FromSlash(localPath))); err == nil {
+		// copied with the static files, like it's served
+		return true, nil
+	}
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Scheme: s.base.Scheme, Host: s.base.Host, Path: localPath},
+		Host:   s.base.Host,
+		Header: make(http.Header),
+	}
+	w := &exportWriter{header: make(http.Header), code: http.StatusOK}
+	if !handler(w, req) {
+		return false, nil
+	}
+	if w.code != http.StatusOK {
+		return true, fmt.Errorf("Couldn't export %v: %v", localPath, w.code)
+	}
+	return true, writeExportFile(outDir, filepath.FromSlash(s.hostPath(localPath)), w.Bytes())
+}
+
+// exportWriter keeps what a handler writes, so a generated file can be exported.
+type exportWriter struct {
+	bytes.Buffer
+	header http.Header
+	code   int
+}
+
+func (w *exportWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *exportWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// exportRedirects writes a page that refreshes to the destination for each redirect,
+// and a `_redirects` file that some static hosts use to answer with a real 301.
+func (s *Site) exportRedirects(c *content, outDir string) (err error) {
+	var sources []string
+	for src := range c.redirectMap {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	list := &bytes.Buffer{}
+	for i := range sources {
+		src := sources[i]
+		dst := c.redirectMap[src]
+		fmt.Fprintf(list, "%s %s 301\n", src, dst)
+		if err = writeExportFile(outDir, indexFile(src), refreshPage(dst)); err != nil {
+			return
+		}
+	}
+	return writeExportFile(outDir, "_redirects", list.Bytes())
+}
+
+// indexFile is the file a static host will serve for the url path.
+// Folders like /blog/ or /blog get an index.html, a file like /privacy.html is the file itself.
+func indexFile(urlPath string) string {
+	if path.Ext(urlPath) != "" {
+		return filepath.FromSlash(urlPath)
+	}
+	if !strings.HasSuffix(urlPath, "/") {
+		urlPath += "/"
+	}
+	return filepath.FromSlash(urlPath + "index.html")
+}
+
+// refreshPage is an HTML page that sends the browser to `location`.
+func refreshPage(location string) []byte {
+	escaped := template.HTMLEscapeString(location)
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset='utf-8'>
+	<title>Redirecting to %s</title>
+	<link rel="canonical" href="%s">
+	<meta http-equiv="refresh" content="0; url=%s">
+</head>
+</html>
+`, escaped, escaped, escaped))
+}
+
+// writeExportFile saves `data` at `name` within the `outDir`, making any folders needed.
+func writeExportFile(outDir, name string, data []byte) error {
+	filename := filepath.Join(outDir, name)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("Couldn't make folder for %v: %v", filename, err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("Couldn't write %v: %v", filename, err)
+	}
+	return nil
+}
+
+// copyFolder copies all the files in `src` into `dst`, it's fine if `src` doesn't exist.
+func copyFolder(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("Couldn't copy %v: %v", name, err)
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("Couldn't make folder %v: %v", target, err)
+			}
+			return nil
+		}
+		return copyFile(name, target)
+	})
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("Couldn't open %v: %v", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("Couldn't create %v: %v", dst, err)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("Couldn't copy %v: %v", src, err)
+	}
+	return out.Close()
+}
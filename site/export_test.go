@@ -0,0 +1,129 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Site_Export(t *testing.T) {
+	// GIVEN a site
+	u, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(u, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outDir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	// WHEN it is exported
+	if err = s.Export(outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the files should contain the pages, static files and redirects
+	type test struct {
+		file     string
+		contains string
+	}
+	tests := []test{
+		{"index.html", "<title>Example.com</title>"},
+		{"blog/index.html", "Post List Example"},
+		{"blog/trip/index.html", "Trip"},
+		{"privacy/index.html", "Privacy Policy"},
+		{"404.html", "Not Found"},
+		{"robots.txt", ""},
+		{"css/index.css", ""},
+		{"privacy.html", `content="0; url=/privacy/"`},
+		{"prototype/index.html", `content="0; url=/blog/"`},
+		{"_redirects", "/privacy.html /privacy/ 301\n"},
+		{"sitemap.xml", "<loc>http://localhost:8009/privacy/</loc>"},
+		{"feed.xml", "<rss"},
+		{"atom.xml", "<feed"},
+		{"blog/feed.xml", "<link>http://localhost:8009/blog/trip/</link>"},
+		{"blog/atom.xml", `<link href="http://localhost:8009/blog/atom.xml" rel="self">`},
+	}
+	hashed, _ := filepath.Glob(filepath.Join(outDir, "css", "index.*.css"))
+	if len(hashed) != 1 {
+		t.Errorf("Expecting the fingerprinted css got %v", hashed)
+	}
+	for i := range tests {
+		data, err := ioutil.ReadFile(filepath.Join(outDir, tests[i].file))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(data), tests[i].contains) {
+			t.Errorf("Expecting %v to contain %v", tests[i].file, tests[i].contains)
+		}
+	}
+}
+
+func Test_Site_Export_template_error(t *testing.T) {
+	// GIVEN a site with a page that has a layout that doesn't exist
+	u, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(u, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.current().pageMap["/privacy/"].Layout = "noexist.html"
+	outDir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	// WHEN it is exported
+	err = s.Export(outDir)
+
+	// THEN it should error about that page
+	if err == nil || !strings.Contains(err.Error(), "/privacy/") {
+		t.Errorf("Expecting an error about /privacy/ got %v", err)
+	}
+
+	// THEN the other pages should still be exported
+	if _, err = os.Stat(filepath.Join(outDir, "index.html")); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Site_Export_robots(t *testing.T) {
+	// GIVEN a site without a robots.txt in static
+	u, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(u, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.staticPath = t.TempDir()
+	outDir := t.TempDir()
+
+	// WHEN it is exported
+	if err = s.Export(outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the generated robots.txt points at the exported sitemap
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "robots.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Sitemap: http://localhost:8009/sitemap.xml") {
+		t.Errorf("Expecting the sitemap in robots.txt got %q", data)
+	}
+	if _, err = os.Stat(filepath.Join(outDir, "sitemap.xml")); err != nil {
+		t.Error(err)
+	}
+}
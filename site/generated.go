@@ -0,0 +1,51 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// generatedHandler serves the files that are made from the content instead of read from `static`.
+// Returns false if the request isn't for one of them.
+func (s *Site) generatedHandler(w http.ResponseWriter, req *http.Request) bool {
+	switch {
+	case req.URL.Path == "/robots.txt":
+		return s.robotsHandler(w, req)
+	case req.URL.Path == "/sitemap.xml", strings.HasPrefix(req.URL.Path, "/sitemap-"):
+		return s.sitemapHandler(w, req)
+	}
+	switch path.Base(req.URL.Path) {
+	case "feed.xml", "atom.xml":
+		return s.feedHandler(w, req)
+	}
+	return false
+}
+
+// feedHandler serves /dir/feed.xml as RSS and /dir/atom.xml as Atom for every directory page.
+func (s *Site) feedHandler(w http.ResponseWriter, req *http.Request) bool {
+	dir, name := path.Split(req.URL.Path)
+	c := s.current()
+	p, ok := c.pageMap[s.hostPath(dir)]
+	if !ok || !(p.Dir || p.Parent == nil) {
+		return false
+	}
+	var data []byte
+	var err error
+	if name == "atom.xml" {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		data, err = p.Atom(s.base, s.hostPath(req.URL.Path), c.loaded)
+	} else {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		data, err = p.RSS(s.base)
+	}
+	if err != nil {
+		s.errLog.Println(500, req.Host, req.URL, "Feed", err)
+		http.Error(w, "Feed Error", http.StatusInternalServerError)
+		return true
+	}
+	w.Write(data)
+	return true
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_Site_ServeHTTP_feeds(t *testing.T) {
+	// GIVEN a site with a blog directory
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		path        string
+		code        int
+		contentType string
+		contains    string
+	}
+	tests := []test{
+		{"/blog/feed.xml", 200, "application/rss+xml; charset=utf-8", "<link>http://localhost:8009/blog/trip/</link>"},
+		{"/blog/atom.xml", 200, "application/atom+xml; charset=utf-8", `<link href="http://localhost:8009/blog/atom.xml" rel="self">`},
+		{"/feed.xml", 200, "application/rss+xml; charset=utf-8", "http://localhost:8009/thumbs/trip.jpg"},
+		{"/privacy/feed.xml", 404, "", ""},
+		{"/noexist/feed.xml", 404, "", ""},
+	}
+
+	for i := range tests {
+		// WHEN a feed is requested
+		req := httptest.NewRequest("GET", address.String()+tests[i].path, nil)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+
+		// THEN it should be served for directories only
+		if w.Code != tests[i].code {
+			t.Errorf("%v expected %v got %v", tests[i].path, tests[i].code, w.Code)
+			continue
+		}
+		if tests[i].code != 200 {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != tests[i].contentType {
+			t.Errorf("%v expected Content-Type %v got %v", tests[i].path, tests[i].contentType, ct)
+		}
+		if !strings.Contains(w.Body.String(), tests[i].contains) {
+			t.Errorf("%v expected to contain %v", tests[i].path, tests[i].contains)
+		}
+	}
+}
@@ -20,16 +20,30 @@ func (s *Site) loadTemplatesAndContent() (err error) {
 		return
 	}
 
+	pageMap := page.MapPages(root)
+	redirectMap := page.MapRedirects(page.Walk(root), s.base)
+
 	// saving only if successful
-	s.templates = templatesCompiled
-	s.pageRoot = root
-	s.pageMap = page.MapPages(root)
-	s.redirectMap = page.MapRedirects(page.Walk(root), s.base)
+	loaded := time.Now()
+	s.content.Store(&content{
+		templates:   templatesCompiled,
+		pageRoot:    root,
+		pageMap:     pageMap,
+		redirectMap: redirectMap,
+		loaded:      loaded,
+		cache:       newPageCache(loaded),
+	})
 	return
 }
 
 // Builds all the templates in the {Site.templatePath}/layouts/*.html path.
 func (s *Site) loadTemplates() (templatesCompiled *template.Template, err error) {
+	return parseTemplates(s.templatePath, s.assets)
+}
+
+// parseTemplates builds the layouts in `templatePath`, with the functions they can use.
+// `asset` fingerprints the url of a static file with its content, so it can be cached forever.
+func parseTemplates(templatePath string, assets *assets) (templatesCompiled *template.Template, err error) {
 	funcMap := template.FuncMap{
 		"mod": func(a int, b int) int {
 			return a % b
@@ -43,8 +57,9 @@ func (s *Site) loadTemplates() (templatesCompiled *template.Template, err error)
 		"html": func(a ...interface{}) template.HTML {
 			return template.HTML(fmt.Sprint(a...))
 		},
+		"asset": assets.url,
 	}
-	layoutPattern := fmt.Sprintf("%s/layouts/*.html", s.templatePath)
+	layoutPattern := fmt.Sprintf("%s/layouts/*.html", templatePath)
 	templatesCompiled, err = template.New("site").Funcs(funcMap).ParseGlob(layoutPattern)
 	if err != nil {
 		return
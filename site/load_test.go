@@ -13,7 +13,7 @@ import (
 func Test_Site_loadTemplatesAndContent(t *testing.T) {
 	testLog := log.New(&bytes.Buffer{}, "", 0)
 	u, _ := url.Parse("http://example.com")
-	s, err := New(u, _templatePath, false, false, testLog, testLog)
+	s, err := New(u, _templatePath, false, testLog, testLog)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -3,6 +3,8 @@
 package site
 
 import (
+	"github.com/robert-wallis/webd/headers"
+	"github.com/robert-wallis/webd/metrics"
 	"github.com/robert-wallis/webd/page"
 	"io"
 	"net/http"
@@ -11,25 +13,14 @@ import (
 
 // ServeHTTP processes requests for the site.  Including dynamic and static content.
 func (s *Site) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	if s.liveRefresh {
-		err := s.loadTemplatesAndContent()
-		if err != nil {
-			s.errLog.Println("liveRefresh Error:", err)
-			// continue to old good version
-		}
-	}
-	if s.redirectHttps && s.base.Scheme == "http" {
-		r := redirect{Host: s.base.Host}
-		s.infoLog.Println("301 to https", req.Host, req.URL)
-		r.HTTPSRedirect(w, req)
-		return
-	}
-	if loc, ok := s.redirectMap[req.URL.Path]; ok {
+	c := s.current()
+	if loc, ok := c.redirectMap[s.sitePath(req.URL.Path)]; ok {
 		s.infoLog.Println("301", req.Host, req.URL)
+		metrics.SetHandler(req, metrics.Redirect)
 		http.Redirect(w, req, loc, http.StatusMovedPermanently)
 		return
 	}
-	p, found, folderRedirect := s.contentPage(req.URL.Path)
+	p, found, folderRedirect := c.contentPage(req.URL.Path)
 	if !found {
 		s.staticHandler(w, req)
 		return
@@ -37,18 +28,43 @@ func (s *Site) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if folderRedirect {
 		u, _ := url.Parse(p.URL)
 		s.infoLog.Println("301", req.Host, req.URL, "to", u.Path)
+		metrics.SetHandler(req, metrics.Redirect)
 		http.Redirect(w, req, page.RelativeBaseOrFullUrl(s.base, p.URL), http.StatusMovedPermanently)
 		return
 	}
-	if err := s.writePage(w, p); err != nil {
-		s.errLog.Println(500, req.Host, req.URL, "Template Execute", err)
-		http.Error(w, "Template Execute Error", http.StatusInternalServerError)
+	metrics.SetHandler(req, metrics.Page)
+	if nonce := headers.Nonce(req); p.NoCache || len(nonce) > 0 {
+		// a nonce is different every time
+		if err := c.writePage(w, p, nonce); err != nil {
+			s.templateError(w, req, err)
+		}
 		return
 	}
-	return
+	cached, err := s.cachedPage(c, req.URL.Path, p)
+	if err != nil {
+		s.templateError(w, req, err)
+		return
+	}
+	if err = cached.serve(w, req); err != nil {
+		s.errLog.Println(req.Host, req.URL, "Write", err)
+	}
+}
+
+// templateError answers a request whose page didn't render.
+func (s *Site) templateError(w http.ResponseWriter, req *http.Request, err error) {
+	s.errLog.Println(500, req.Host, req.URL, "Template Execute", err)
+	metrics.TemplateErrors.Inc(s.base.Hostname())
+	http.Error(w, "Template Execute Error", http.StatusInternalServerError)
+}
+
+// pageData is what a layout is executed with, the page and the values of the request it's rendered for.
+type pageData struct {
+	*page.Page
+	Nonce string // for the nonce attribute of inline scripts and styles, empty unless the CSP has one
 }
 
-func (s *Site) writePage(w io.Writer, p *page.Page) (err error) {
-	err = s.templates.ExecuteTemplate(w, p.Layout, p)
+// writePage renders `p` with its layout, `nonce` is the CSP nonce of the request or empty.
+func (c *content) writePage(w io.Writer, p *page.Page, nonce string) (err error) {
+	err = c.templates.ExecuteTemplate(w, p.Layout, &pageData{Page: p, Nonce: nonce})
 	return
 }
@@ -5,12 +5,15 @@ package site
 import (
 	"bytes"
 	"errors"
+	"github.com/robert-wallis/webd/config"
+	"github.com/robert-wallis/webd/headers"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockResponseWriter struct {
@@ -39,7 +42,7 @@ func Test_Site_ServeHTTP(t *testing.T) {
 	// GIVEN an initialized site instance
 	address, _ := url.Parse("http://localhost:8009")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, testLog)
+	s, err := New(address, _templatePath, false, testLog, testLog)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -61,13 +64,16 @@ func Test_Site_ServeHTTP_liveRefresh_error(t *testing.T) {
 	testLog := log.New(&bytes.Buffer{}, "", 0)
 	errBuf := &bytes.Buffer{}
 	errLog := log.New(errBuf, "", 0)
-	s, err := New(address, _templatePath, true, false, testLog, errLog)
+	s, err := New(address, _templatePath, true, testLog, errLog)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// WHEN a the templates are broken and a new request comes in
+	defer s.Close()
+
+	// WHEN a the templates are broken, a refresh happens, and a new request comes in
 	s.templatePath = "noexist"
+	s.refresh()
 	req := httptest.NewRequest("GET", address.String()+"/", nil)
 	w := httptest.NewRecorder()
 	s.ServeHTTP(w, req)
@@ -89,7 +95,7 @@ func Test_Site_ServeHTTP_sad_path(t *testing.T) {
 	testLog := log.New(&bytes.Buffer{}, "", 0)
 	errBuf := &bytes.Buffer{}
 	errLog := log.New(errBuf, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, errLog)
+	s, err := New(address, _templatePath, false, testLog, errLog)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -120,50 +126,12 @@ func Test_Site_ServeHTTP_sad_path(t *testing.T) {
 	}
 }
 
-func Test_Site_ServeHTTP_https_redirect(t *testing.T) {
-	address, _ := url.Parse("http://localhost:8009")
-	testLog := log.New(&bytes.Buffer{}, "", 0)
-	errBuf := &bytes.Buffer{}
-	errLog := log.New(errBuf, "", 0)
-	s, err := New(address, _templatePath, false, true, testLog, errLog)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	type test struct {
-		path     string
-		code     int
-		location string
-	}
-
-	// should just reflect request without thinking about it to https
-	tests := []test{
-		{"/", 301, "https://localhost:8009/"},
-		{"/privacy.html", 301, "https://localhost:8009/privacy.html"},
-		{"/privacy", 301, "https://localhost:8009/privacy"},
-		{"/noexist", 301, "https://localhost:8009/noexist"},
-	}
-
-	for i := range tests {
-		req := httptest.NewRequest("GET", address.String()+tests[i].path, nil)
-		w := httptest.NewRecorder()
-		s.ServeHTTP(w, req)
-		if w.Code != tests[i].code {
-			t.Error(tests[i].path, "expected", tests[i].code, "actual", w.Code, errBuf.String())
-		}
-		loc := w.HeaderMap.Get("location")
-		if w.Code >= 300 && w.Code < 400 && loc != tests[i].location {
-			t.Error(tests[i].path, "expected", tests[i].location, "redirect location:", loc, w.Code)
-		}
-	}
-}
-
 func Test_Site_ServeHTTP_closed_writer(t *testing.T) {
 	address, _ := url.Parse("http://localhost:8009")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
 	errBuf := &bytes.Buffer{}
 	errLog := log.New(errBuf, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, errLog)
+	s, err := New(address, _templatePath, false, testLog, errLog)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -182,3 +150,77 @@ func Test_Site_ServeHTTP_closed_writer(t *testing.T) {
 		t.Errorf(`expected "%v" got "%v"`, testError, errStr)
 	}
 }
+
+func Test_Site_ServeHTTP_nonce(t *testing.T) {
+	// GIVEN a site with a CSP nonce
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := headers.New(s, config.ConfigHeaders{CSP: "script-src 'nonce-" + headers.NoncePlaceholder + "'"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN a page is requested
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", address.String()+"/", nil))
+
+	// THEN the inline script has the nonce of the header
+	csp := w.Header().Get("Content-Security-Policy")
+	nonce := strings.TrimSuffix(strings.TrimPrefix(csp, "script-src 'nonce-"), "'")
+	if len(nonce) == 0 || nonce == headers.NoncePlaceholder {
+		t.Fatalf("CSP without a nonce %q", csp)
+	}
+	if !strings.Contains(w.Body.String(), `nonce="`+nonce+`"`) {
+		t.Errorf("Expecting the script to have nonce %v", nonce)
+	}
+}
+
+// blockingWriter is a client that doesn't read its response until it's released.
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan bool
+	release chan bool
+}
+
+func (w *blockingWriter) Write(data []byte) (int, error) {
+	w.writing <- true
+	<-w.release
+	return w.ResponseRecorder.Write(data)
+}
+
+func Test_Site_ServeHTTP_slow_client_reload(t *testing.T) {
+	// GIVEN a site with a slow client in the middle of a response
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan bool, 1), release: make(chan bool)}
+	go s.ServeHTTP(slow, httptest.NewRequest("GET", address.String()+"/", nil))
+	<-slow.writing
+	defer close(slow.release)
+
+	// WHEN the site reloads, and another request comes in
+	reloaded := make(chan error)
+	go func() {
+		err := s.loadTemplatesAndContent()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", address.String()+"/privacy/", nil))
+		reloaded <- err
+	}()
+
+	// THEN neither waits for the slow client
+	select {
+	case err = <-reloaded:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The reload waited for the slow client")
+	}
+}
@@ -4,58 +4,144 @@
 package site
 
 import (
+	"github.com/robert-wallis/webd/compress"
+	"github.com/robert-wallis/webd/metrics"
 	"github.com/robert-wallis/webd/page"
+	"github.com/robert-wallis/webd/watch"
 	"html/template"
 	"log"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// refreshDelay waits for a burst of file changes to settle before reloading.
+const refreshDelay = 200 * time.Millisecond
+
 // Site controls the handling of HTTP traffic to a site.
 type Site struct {
-	base          *url.URL
-	templates     *template.Template
-	templatePath  string
-	contentPath   string
-	staticPath    string
-	pageRoot      *page.Page
-	pageMap       map[string]*page.Page
-	redirectMap   map[string]string
-	fileHandler   http.Handler
-	liveRefresh   bool
-	redirectHttps bool
-	infoLog       *log.Logger
-	errLog        *log.Logger
+	base         *url.URL
+	content      atomic.Value // *content, a reload replaces all of it at once
+	mu           sync.RWMutex // guards cacheControl
+	templatePath string
+	contentPath  string
+	staticPath   string
+	fileHandler  http.Handler
+	assets       *assets // fingerprints for the `asset` template function
+	cacheControl string  // Cache-Control of the static files that aren't fingerprinted
+	liveRefresh  bool
+	watcher      *watch.Watcher
+	infoLog      *log.Logger
+	errLog       *log.Logger
+}
+
+// content is the templates and pages of a site, a request uses the same version of them from start to end.
+// It isn't locked while a request is served, so a slow client doesn't hold up a reload, or the requests after it.
+type content struct {
+	templates   *template.Template
+	pageRoot    *page.Page
+	pageMap     map[string]*page.Page
+	redirectMap map[string]string
+	loaded      time.Time  // when it was loaded, the date of pages without a `dateupdated`
+	cache       *pageCache // rendered pages, replaced on every reload
 }
 
 // New creates and configures a Site.  It loads the templates and content.
 // `templatePath` is the place that contains the `layouts` folder.
 // `templatePath` contains the `content` folder that is turned into Page objects.
-func New(base *url.URL, templatePath string, liveRefresh bool, redirectHttps bool, infoLog, errLog *log.Logger) (s *Site, err error) {
+// `base` can have a path when the site is mounted under a prefix of its host, the pages and static files are under it.
+// `liveRefresh` watches the `layouts`, `content` and `static` folders and reloads when they change.
+func New(base *url.URL, templatePath string, liveRefresh bool, infoLog, errLog *log.Logger) (s *Site, err error) {
 	staticPath := path.Join(templatePath, "static")
 	s = &Site{
-		base:          base,
-		templatePath:  templatePath,
-		contentPath:   path.Join(templatePath, "content"),
-		staticPath:    staticPath,
-		fileHandler:   http.FileServer(http.Dir(staticPath)),
-		liveRefresh:   liveRefresh,
-		infoLog:       infoLog,
-		redirectHttps: redirectHttps,
-		errLog:        errLog,
+		base:         base,
+		templatePath: templatePath,
+		contentPath:  path.Join(templatePath, "content"),
+		staticPath:   staticPath,
+		fileHandler:  compress.FileServer(staticPath),
+		assets:       newAssets(staticPath, strings.TrimSuffix(base.Path, "/")),
+		liveRefresh:  liveRefresh,
+		infoLog:      infoLog,
+		errLog:       errLog,
 	}
 	if err = s.loadTemplatesAndContent(); err != nil {
 		return nil, err
 	}
+	if liveRefresh {
+		folders := []string{
+			path.Join(templatePath, "layouts"),
+			s.contentPath,
+			s.staticPath,
+		}
+		if s.watcher, err = watch.New(folders, refreshDelay, errLog, s.refresh); err != nil {
+			return nil, err
+		}
+	}
 	return
 }
 
+// SetCacheControl is the Cache-Control header sent with the static files that aren't fingerprinted, empty doesn't send one.
+// Fingerprinted files always get ImmutableCacheControl.
+func (s *Site) SetCacheControl(value string) {
+	s.mu.Lock()
+	s.cacheControl = value
+	s.mu.Unlock()
+}
+
+// staticCacheControl is the Cache-Control of the static files that aren't fingerprinted.
+func (s *Site) staticCacheControl() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cacheControl
+}
+
+// current is the content to serve a request from.
+func (s *Site) current() *content {
+	return s.content.Load().(*content)
+}
+
+// Close stops watching for changes when liveRefresh is on.
+func (s *Site) Close() (err error) {
+	if s.watcher != nil {
+		err = s.watcher.Close()
+	}
+	return
+}
+
+// refresh reloads the templates and content after a change, keeping the old version if there's an error.
+func (s *Site) refresh() {
+	if err := s.loadTemplatesAndContent(); err != nil {
+		s.errLog.Println("liveRefresh Error:", err)
+		metrics.ContentReloads.Inc(s.base.Hostname(), "failure")
+		return
+	}
+	metrics.ContentReloads.Inc(s.base.Hostname(), "success")
+	s.infoLog.Println("liveRefresh", s.base)
+}
+
+// sitePath is `urlPath` within the site, without the path of its base url when it's mounted under a prefix.
+func (s *Site) sitePath(urlPath string) string {
+	prefix := strings.TrimSuffix(s.base.Path, "/")
+	if len(prefix) == 0 || !strings.HasPrefix(urlPath, prefix+"/") {
+		return urlPath
+	}
+	return strings.TrimPrefix(urlPath, prefix)
+}
+
+// hostPath is the path on the host of `localPath` within the site, the opposite of sitePath.
+func (s *Site) hostPath(localPath string) string {
+	return strings.TrimSuffix(s.base.Path, "/") + localPath
+}
+
 // contentPage finds the page that matches the url
-func (s *Site) contentPage(path string) (page *page.Page, found, folderRedirect bool) {
-	if page, found = s.pageMap[path]; !found {
+func (c *content) contentPage(path string) (page *page.Page, found, folderRedirect bool) {
+	if page, found = c.pageMap[path]; !found {
 		slashed := path + "/"
-		if page, found = s.pageMap[slashed]; found {
+		if page, found = c.pageMap[slashed]; found {
 			folderRedirect = true
 		}
 		return
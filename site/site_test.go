@@ -4,9 +4,13 @@ package site
 
 import (
 	"bytes"
+	"io/ioutil"
 	"log"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 var _templatePath = "../example"
@@ -17,7 +21,7 @@ func Test_Site_New(t *testing.T) {
 
 	// WHEN the server is created
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	_, err := New(u, _templatePath, false, false, testLog, testLog)
+	_, err := New(u, _templatePath, false, testLog, testLog)
 
 	// THEN it shouldn't generate an error
 	if err != nil {
@@ -32,7 +36,7 @@ func Test_Site_New_badPath(t *testing.T) {
 	// WHEN the test path is invalid
 	templatesPath := "noexist"
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	_, err := New(u, templatesPath, false, false, testLog, testLog)
+	_, err := New(u, templatesPath, false, testLog, testLog)
 
 	// THEN it should fail
 	if err == nil {
@@ -43,7 +47,7 @@ func Test_Site_New_badPath(t *testing.T) {
 func Test_Site_contentPage(t *testing.T) {
 	address, _ := url.Parse("http://localhost:8009")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, testLog)
+	s, err := New(address, _templatePath, false, testLog, testLog)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -62,7 +66,7 @@ func Test_Site_contentPage(t *testing.T) {
 		{"/noexist", "", false, false},
 	}
 	for i := range tests {
-		p, found, redirect := s.contentPage(tests[i].location)
+		p, found, redirect := s.current().contentPage(tests[i].location)
 		if found != tests[i].found {
 			t.Errorf("%v expected found %v got %v", tests[i].location, tests[i].found, found)
 		}
@@ -74,3 +78,65 @@ func Test_Site_contentPage(t *testing.T) {
 		}
 	}
 }
+
+func Test_Site_New_liveRefresh(t *testing.T) {
+	// GIVEN a live refreshing site in a folder that can be changed
+	dir, err := ioutil.TempDir("", "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = copyDir(filepath.Join(_templatePath, "layouts"), filepath.Join(dir, "layouts")); err != nil {
+		t.Fatal(err)
+	}
+	if err = copyDir(filepath.Join(_templatePath, "content"), filepath.Join(dir, "content")); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(u, dir, true, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// WHEN the content changes
+	index := filepath.Join(dir, "content", "index.yaml")
+	if err = ioutil.WriteFile(index, []byte("title: Changed\nlayout: index.html\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN the site should reload it without a request
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		s.mu.RLock()
+		title := s.current().pageMap["/"].Title
+		s.mu.RUnlock()
+		if title == "Changed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expecting the title to be reloaded, got %v", title)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// copyDir copies the files in `src` into a new folder `dst`.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
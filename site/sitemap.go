@@ -0,0 +1,110 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/robert-wallis/webd/page"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSitemapURLs is the most URLs a single sitemap can have, larger sites get a sitemap index.
+var maxSitemapURLs = 50000
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// sitemapHandler serves /sitemap.xml, or when there are too many pages for one,
+// a sitemap index at /sitemap.xml that lists /sitemap-1.xml, /sitemap-2.xml and so on.
+func (s *Site) sitemapHandler(w http.ResponseWriter, req *http.Request) bool {
+	urls := s.sitemapURLs()
+	pages := (len(urls) + maxSitemapURLs - 1) / maxSitemapURLs
+	var doc interface{}
+	switch req.URL.Path {
+	case "/sitemap.xml":
+		if pages <= 1 {
+			doc = sitemapURLSet{XMLNS: sitemapNamespace, URLs: urls}
+			break
+		}
+		index := sitemapIndex{XMLNS: sitemapNamespace}
+		for i := 1; i <= pages; i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: page.AbsoluteUrl(s.base, s.hostPath(fmt.Sprintf("/sitemap-%d.xml", i)))})
+		}
+		doc = index
+	default:
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/sitemap-"), ".xml"))
+		if err != nil || pages <= 1 || n < 1 || n > pages {
+			return false
+		}
+		end := n * maxSitemapURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		doc = sitemapURLSet{XMLNS: sitemapNamespace, URLs: urls[(n-1)*maxSitemapURLs : end]}
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		s.errLog.Println(500, req.Host, req.URL, "Sitemap", err)
+		http.Error(w, "Sitemap Error", http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+	return true
+}
+
+// sitemapURLs lists every page that should be in the sitemap, sorted by path.
+// External pages and redirects aren't in the page map, so they are left out, as are `nositemap` pages.
+func (s *Site) sitemapURLs() (urls []sitemapURL) {
+	pageMap := s.current().pageMap
+	var paths []string
+	for p := range pageMap {
+		if !pageMap[p].NoSitemap {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for i := range paths {
+		p := pageMap[paths[i]]
+		u := sitemapURL{Loc: page.AbsoluteUrl(s.base, p.URL)}
+		if !p.DateUpdated.IsZero() {
+			u.LastMod = p.DateUpdated.Format(time.RFC3339)
+		}
+		urls = append(urls, u)
+	}
+	return
+}
+
+// robotsHandler serves a robots.txt that allows everything and points at the sitemap.
+// It's only used when there isn't a robots.txt in `static`.
+func (s *Site) robotsHandler(w http.ResponseWriter, req *http.Request) bool {
+	buf := &bytes.Buffer{}
+	buf.WriteString("User-agent: *\nAllow: /\n\n")
+	fmt.Fprintf(buf, "Sitemap: %s\n", page.AbsoluteUrl(s.base, s.hostPath("/sitemap.xml")))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(buf.Bytes())
+	return true
+}
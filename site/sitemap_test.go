@@ -0,0 +1,130 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_Site_ServeHTTP_sitemap(t *testing.T) {
+	// GIVEN a site
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN the sitemap is requested
+	req := httptest.NewRequest("GET", address.String()+"/sitemap.xml", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	// THEN it should list the pages
+	if w.Code != 200 {
+		t.Fatalf("Expecting 200 got %v", w.Code)
+	}
+	body := w.Body.String()
+	expected := []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		"<loc>http://localhost:8009/</loc>",
+		"<loc>http://localhost:8009/blog/mixbody/</loc>",
+		"<lastmod>2012-07-05T12:00:00-08:00</lastmod>",
+	}
+	for i := range expected {
+		if !strings.Contains(body, expected[i]) {
+			t.Errorf("Expecting %v in %v", expected[i], body)
+		}
+	}
+
+	// THEN it shouldn't list opted out pages, external links or redirects
+	unexpected := []string{"/404/", "github.com", "/privacy.html", "/prototype"}
+	for i := range unexpected {
+		if strings.Contains(body, unexpected[i]) {
+			t.Errorf("Not expecting %v in %v", unexpected[i], body)
+		}
+	}
+}
+
+func Test_Site_ServeHTTP_sitemap_index(t *testing.T) {
+	// GIVEN a site with more pages than fit in a sitemap
+	address, _ := url.Parse("http://localhost:8009")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(max int) { maxSitemapURLs = max }(maxSitemapURLs)
+	maxSitemapURLs = 3
+	count := len(s.sitemapURLs())
+	pages := (count + 2) / 3
+
+	// WHEN the sitemap is requested
+	req := httptest.NewRequest("GET", address.String()+"/sitemap.xml", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	// THEN it should be an index of the split sitemaps
+	body := w.Body.String()
+	if !strings.Contains(body, "<sitemapindex") || strings.Count(body, "<sitemap>") != pages {
+		t.Errorf("Expecting an index of %v sitemaps got %v", pages, body)
+	}
+
+	// THEN each split sitemap should have its part of the URLs
+	found := 0
+	for i := 1; i <= pages+1; i++ {
+		path := fmt.Sprintf("/sitemap-%d.xml", i)
+		req := httptest.NewRequest("GET", address.String()+path, nil)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if i > pages {
+			if w.Code != 404 {
+				t.Errorf("%v expecting 404 got %v", path, w.Code)
+			}
+			continue
+		}
+		n := strings.Count(w.Body.String(), "<url>")
+		if n == 0 || n > 3 {
+			t.Errorf("%v expecting 1 to 3 urls got %v", path, n)
+		}
+		found += n
+	}
+	if found != count {
+		t.Errorf("Expecting %v urls across the sitemaps got %v", count, found)
+	}
+}
+
+func Test_Site_robotsHandler(t *testing.T) {
+	// GIVEN a site
+	address, _ := url.Parse("https://example.com")
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	s, err := New(address, _templatePath, false, testLog, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN the generated robots.txt is requested
+	req := httptest.NewRequest("GET", address.String()+"/robots.txt", nil)
+	w := httptest.NewRecorder()
+	s.robotsHandler(w, req)
+
+	// THEN it should point at the sitemap
+	if !strings.Contains(w.Body.String(), "Sitemap: https://example.com/sitemap.xml\n") {
+		t.Errorf("Expecting the sitemap in %v", w.Body.String())
+	}
+
+	// WHEN there's a static robots.txt
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	// THEN the static one should win
+	if strings.Contains(w.Body.String(), "Sitemap:") {
+		t.Errorf("Expecting the static robots.txt got %v", w.Body.String())
+	}
+}
@@ -3,31 +3,75 @@
 package site
 
 import (
+	"github.com/robert-wallis/webd/headers"
+	"github.com/robert-wallis/webd/metrics"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 )
 
+// staticHandler serves the files in `static`, and the generated ones, by their path within the site.
 func (s *Site) staticHandler(w http.ResponseWriter, req *http.Request) {
+	if local := s.sitePath(req.URL.Path); local != req.URL.Path {
+		req = withPath(req, local)
+	}
 	filePath := path.Join(s.staticPath, req.URL.Path)
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if s.assetHandler(w, req) || s.generatedHandler(w, req) {
+			return
+		}
 		s.notFoundHandler(w, req)
 		return
 	}
+	metrics.SetHandler(req, metrics.Static)
+	if cacheControl := s.staticCacheControl(); len(cacheControl) > 0 {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
 	s.fileHandler.ServeHTTP(w, req)
 }
 
+// assetHandler serves the static file a fingerprinted url is for, false if it isn't one.
+// It can be cached forever, unless the file changed since the url was made.
+func (s *Site) assetHandler(w http.ResponseWriter, req *http.Request) bool {
+	name, current, found := s.assets.lookup(req.URL.Path)
+	if !found {
+		return false
+	}
+	metrics.SetHandler(req, metrics.Static)
+	if current {
+		w.Header().Set("Cache-Control", ImmutableCacheControl)
+	} else if cacheControl := s.staticCacheControl(); len(cacheControl) > 0 {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	s.fileHandler.ServeHTTP(w, withPath(req, name))
+	return true
+}
+
+// withPath is a copy of `req` for `urlPath`, like http.StripPrefix does.
+func withPath(req *http.Request, urlPath string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.URL = new(url.URL)
+	*r.URL = *req.URL
+	r.URL.Path = urlPath
+	r.URL.RawPath = ""
+	return r
+}
+
 func (s *Site) notFoundHandler(w http.ResponseWriter, req *http.Request) {
 	s.infoLog.Println(404, req.Host, req.URL)
-	p, found, _ := s.contentPage("/404/")
+	c := s.current()
+	p, found, _ := c.contentPage(s.hostPath("/404/"))
 	if !found {
 		s.errLog.Println(404, req.Host, req.URL, "Error: 404.yaml template not found")
 		http.Error(w, "Resource Not Found", http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(404)
-	if err := s.writePage(w, p); err != nil {
+	if err := c.writePage(w, p, headers.Nonce(req)); err != nil {
 		s.errLog.Println(500, req.Host, req.URL, "Template Execute", err)
+		metrics.TemplateErrors.Inc(s.base.Hostname())
 		http.Error(w, "Template Execute Error", http.StatusInternalServerError)
 		return
 	}
@@ -18,7 +18,7 @@ import (
 func Test_Site_staticHandler(t *testing.T) {
 	address, _ := url.Parse("http://localhost:8009")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, testLog)
+	s, err := New(address, _templatePath, false, testLog, testLog)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,16 +47,17 @@ func Test_Site_notFoundHandler(t *testing.T) {
 	// GIVEN the page path that has no 404 page
 	address, _ := url.Parse("http://localhost:8009")
 	testLog := log.New(&bytes.Buffer{}, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, testLog)
+	s, err := New(address, _templatePath, false, testLog, testLog)
 	if err != nil {
 		t.Fatal(err)
 	}
 	// no 404 page in page content
 	s.contentPath = "../page/test_content"
-	if s.pageRoot, err = s.loadContent(); err != nil {
+	root, err := s.loadContent()
+	if err != nil {
 		t.Fatal(err)
 	}
-	s.pageMap = page.MapPages(s.pageRoot)
+	s.current().pageMap = page.MapPages(root)
 
 	// WHEN the request is given to a page that doesn't exist
 	req := httptest.NewRequest("GET", fmt.Sprintf("http://%s/noexist", address), nil)
@@ -74,7 +75,7 @@ func Test_Site_notFoundHandler_closed_writer(t *testing.T) {
 	testLog := log.New(&bytes.Buffer{}, "", 0)
 	errBuf := &bytes.Buffer{}
 	errLog := log.New(errBuf, "", 0)
-	s, err := New(address, _templatePath, false, false, testLog, errLog)
+	s, err := New(address, _templatePath, false, testLog, errLog)
 	if err != nil {
 		t.Fatal(err)
 	}
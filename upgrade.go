@@ -0,0 +1,14 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignal asks a multiple site webd to hand its sockets to a new webd, then stop.
+var upgradeSignal os.Signal = syscall.SIGUSR2
@@ -0,0 +1,10 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package main
+
+import (
+	"os"
+)
+
+// upgradeSignal is nil on windows, it has no SIGUSR2 and can't hand off sockets.
+var upgradeSignal os.Signal
@@ -0,0 +1,181 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+// Package to watch files and folders and get notified once a burst of changes settles down.
+package watch
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Watcher calls a function after the watched files or folders change.
+type Watcher struct {
+	fs       *fsnotify.Watcher
+	delay    time.Duration
+	files    map[string]bool // files watched through their parent folder
+	folders  map[string]bool // folders watched recursively
+	changed  func()
+	errorLog *log.Logger
+	timer    *time.Timer
+	mu       sync.Mutex // guards timer, files and folders
+	done     chan struct{}
+}
+
+// New watches `paths` and calls `changed` once no more changes have been seen for `delay`.
+// A path to a file watches only that file, even if it's replaced by an editor.
+// A path to a folder watches everything inside it, including sub-folders created later.
+// Paths that don't exist are skipped.
+func New(paths []string, delay time.Duration, errorLog *log.Logger, changed func()) (w *Watcher, err error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create file watcher: %v", err)
+	}
+	w = &Watcher{
+		fs:       fs,
+		delay:    delay,
+		files:    make(map[string]bool),
+		folders:  make(map[string]bool),
+		changed:  changed,
+		errorLog: errorLog,
+		done:     make(chan struct{}),
+	}
+	for p := range paths {
+		if err = w.add(filepath.Clean(paths[p])); err != nil {
+			fs.Close()
+			return nil, err
+		}
+	}
+	go w.run()
+	return
+}
+
+// Close stops watching, a pending change notification is dropped.
+func (w *Watcher) Close() error {
+	close(w.done)
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	return w.fs.Close()
+}
+
+// Add watches more paths, like New, the ones already watched stay watched.
+func (w *Watcher) Add(paths ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for p := range paths {
+		if err := w.add(filepath.Clean(paths[p])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add starts watching a file through its folder, or a folder and all its sub-folders.
+func (w *Watcher) add(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't watch %v: %v", path, err)
+	}
+	if !info.IsDir() {
+		w.files[path] = true
+		if err = w.fs.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("Couldn't watch %v: %v", path, err)
+		}
+		return nil
+	}
+	w.folders[path] = true
+	return w.addTree(path)
+}
+
+// addTree watches `folder` and every folder below it.
+func (w *Watcher) addTree(folder string) error {
+	return filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("Couldn't watch %v: %v", path, err)
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if err = w.fs.Add(path); err != nil {
+			return fmt.Errorf("Couldn't watch %v: %v", path, err)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if !w.watched(event.Name) {
+				continue
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err = w.addTree(event.Name); err != nil {
+						w.errorLog.Println(err)
+					}
+				}
+			}
+			w.trigger()
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			w.errorLog.Println("Watch Error:", err)
+		}
+	}
+}
+
+// watched is true if the name is one of the files or inside one of the folders being watched.
+func (w *Watcher) watched(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	name = filepath.Clean(name)
+	if w.files[name] {
+		return true
+	}
+	for folder := range w.folders {
+		if rel, err := filepath.Rel(folder, name); err == nil && rel != ".." && !startsWithParent(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// trigger restarts the delay, so `changed` is only called once for a burst of changes.
+func (w *Watcher) trigger() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(w.delay, func() {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		w.changed()
+	})
+}
+
+// startsWithParent is true for relative paths like ../other
+func startsWithParent(rel string) bool {
+	return len(rel) > 3 && rel[:3] == ".."+string(filepath.Separator)
+}
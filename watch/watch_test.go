@@ -0,0 +1,126 @@
+// Copyright (C) 2018 Robert A. Wallis, All Rights Reserved.
+
+package watch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Watcher_file(t *testing.T) {
+	// GIVEN a watched file next to another file
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	watched := filepath.Join(dir, "sites.yaml")
+	other := filepath.Join(dir, "other.yaml")
+	ioutil.WriteFile(watched, []byte("a"), 0644)
+	ioutil.WriteFile(other, []byte("a"), 0644)
+	changed := make(chan bool, 10)
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	w, err := New([]string{watched}, 50*time.Millisecond, testLog, func() { changed <- true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// WHEN the other file changes
+	ioutil.WriteFile(other, []byte("b"), 0644)
+
+	// THEN nothing should be notified
+	select {
+	case <-changed:
+		t.Error("Shouldn't notify for a file that isn't watched")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// WHEN the watched file changes a few times
+	for i := 0; i < 3; i++ {
+		ioutil.WriteFile(watched, []byte{byte(i)}, 0644)
+	}
+
+	// THEN it should be notified only once
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change notification")
+	}
+	select {
+	case <-changed:
+		t.Error("Expected a burst of changes to notify once")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func Test_Watcher_folder(t *testing.T) {
+	// GIVEN a watched folder
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	changed := make(chan bool, 10)
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	w, err := New([]string{dir, filepath.Join(dir, "noexist")}, 50*time.Millisecond, testLog, func() { changed <- true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// WHEN a sub folder is created
+	sub := filepath.Join(dir, "sub")
+	if err = os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change notification for a new folder")
+	}
+
+	// THEN files in the new sub folder should be watched
+	ioutil.WriteFile(filepath.Join(sub, "page.yaml"), []byte("title: New"), 0644)
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change notification for a file in a new folder")
+	}
+}
+
+func Test_Watcher_Add(t *testing.T) {
+	// GIVEN a watcher, and a folder it doesn't watch yet
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	folder := filepath.Join(dir, "sites.d")
+	os.Mkdir(folder, 0755)
+	changed := make(chan bool, 10)
+	testLog := log.New(&bytes.Buffer{}, "", 0)
+	w, err := New([]string{filepath.Join(dir, "sites.yaml")}, 50*time.Millisecond, testLog, func() { changed <- true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// WHEN it's added, and a file in it changes
+	if err = w.Add(folder); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(folder, "new.yaml"), []byte("a"), 0644)
+
+	// THEN it should be notified
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change notification")
+	}
+}